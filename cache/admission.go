@@ -0,0 +1,200 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 13:40:27
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 13:40:27
+ * Description: 内存缓存 TinyLFU 准入策略
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// AdmissionPolicy 容量已满时新条目的准入策略
+type AdmissionPolicy string
+
+const (
+	AdmissionNone    AdmissionPolicy = ""        // 总是准入
+	AdmissionTinyLFU AdmissionPolicy = "tinylfu" // 仅准入访问频率高于淘汰对象的新条目
+
+	minSketchWidth     = 1024
+	defaultSketchWidth = 1 << 16 // 仅按字节限制容量时的默认计数器宽度
+	sketchDepth        = 4
+	sketchResetFactor  = 10 // 累计记录次数达到计数器宽度的倍数后衰减
+)
+
+// newAdmissionFilter 按名称创建准入过滤器，未启用时返回 nil
+func newAdmissionFilter(policy AdmissionPolicy, maxEntries int) (*tinyLFU, error) {
+	switch policy {
+	case AdmissionNone:
+		return nil, nil
+	case AdmissionTinyLFU:
+		width := defaultSketchWidth
+		if maxEntries > 0 {
+			width = maxEntries
+		}
+		return newTinyLFU(width), nil
+	default:
+		return nil, fmt.Errorf("unsupported admission policy: %s", policy)
+	}
+}
+
+// tinyLFU 频率估计准入过滤器（参考 Caffeine/Ristretto）
+// 由 doorkeeper 布隆过滤器挡住只出现一次的键，Count-Min Sketch 记录近似频率，
+// 累计记录次数达到阈值后所有计数减半，使频率随时间衰减
+type tinyLFU struct {
+	sketch    *countMinSketch
+	door      *bloomFilter
+	additions int
+	resetAt   int
+}
+
+func newTinyLFU(width int) *tinyLFU {
+	if width < minSketchWidth {
+		width = minSketchWidth
+	}
+	width = nextPowerOfTwo(width)
+	return &tinyLFU{
+		sketch:  newCountMinSketch(width),
+		door:    newBloomFilter(width * 4),
+		resetAt: width * sketchResetFactor,
+	}
+}
+
+// increment 记录一次访问
+func (t *tinyLFU) increment(ref entryRef) {
+	h := hashEntryRef(ref)
+	// 首次出现只写入 doorkeeper，避免一次性键占用计数器
+	if t.door.addIfAbsent(h) {
+		t.sketch.increment(h)
+	}
+
+	if t.additions++; t.additions >= t.resetAt {
+		t.sketch.reset()
+		t.door.clear()
+		t.additions = 0
+	}
+}
+
+// estimate 估算访问频率
+func (t *tinyLFU) estimate(ref entryRef) int {
+	h := hashEntryRef(ref)
+	freq := t.sketch.estimate(h)
+	if t.door.contains(h) {
+		freq++
+	}
+	return freq
+}
+
+// admit 候选条目频率高于淘汰对象时才准入
+func (t *tinyLFU) admit(candidate, victim entryRef) bool {
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+// hashEntryRef 计算条目哈希
+func hashEntryRef(ref entryRef) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(ref.key))
+	if ref.hash {
+		_, _ = h.Write([]byte{1})
+	}
+	return h.Sum64()
+}
+
+// nextPowerOfTwo 返回不小于 n 的最小 2 的幂
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// countMinSketch 4 位计数器的 Count-Min Sketch，每个 uint64 存放 16 个计数器
+type countMinSketch struct {
+	rows [sketchDepth][]uint64
+	mask uint64
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	s := &countMinSketch{mask: uint64(width - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint64, width/16+1)
+	}
+	return s
+}
+
+// index 返回第 row 行的计数器下标
+func (s *countMinSketch) index(h uint64, row int) uint64 {
+	h += uint64(row) * (h>>32 | 1) * 0x9e3779b97f4a7c15
+	return (h ^ h>>31) & s.mask
+}
+
+func (s *countMinSketch) increment(h uint64) {
+	for row := range s.rows {
+		i := s.index(h, row)
+		word, shift := i/16, (i%16)*4
+		if (s.rows[row][word]>>shift)&0xf < 15 {
+			s.rows[row][word] += 1 << shift
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(h uint64) int {
+	min := 15
+	for row := range s.rows {
+		i := s.index(h, row)
+		if v := int((s.rows[row][i/16] >> ((i % 16) * 4)) & 0xf); v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// reset 所有计数器减半
+func (s *countMinSketch) reset() {
+	for row := range s.rows {
+		for i := range s.rows[row] {
+			s.rows[row][i] = (s.rows[row][i] >> 1) & 0x7777777777777777
+		}
+	}
+}
+
+// bloomFilter doorkeeper 使用的布隆过滤器
+type bloomFilter struct {
+	bits []uint64
+	mask uint64
+}
+
+func newBloomFilter(size int) *bloomFilter {
+	size = nextPowerOfTwo(size)
+	return &bloomFilter{bits: make([]uint64, size/64+1), mask: uint64(size - 1)}
+}
+
+// positions 由一个哈希派生两个位置
+func (b *bloomFilter) positions(h uint64) (uint64, uint64) {
+	return h & b.mask, (h>>32 ^ h*0x9e3779b97f4a7c15) & b.mask
+}
+
+// addIfAbsent 写入哈希，已存在时返回 true
+func (b *bloomFilter) addIfAbsent(h uint64) bool {
+	p1, p2 := b.positions(h)
+	present := b.bits[p1/64]&(1<<(p1%64)) != 0 && b.bits[p2/64]&(1<<(p2%64)) != 0
+	b.bits[p1/64] |= 1 << (p1 % 64)
+	b.bits[p2/64] |= 1 << (p2 % 64)
+	return present
+}
+
+func (b *bloomFilter) contains(h uint64) bool {
+	p1, p2 := b.positions(h)
+	return b.bits[p1/64]&(1<<(p1%64)) != 0 && b.bits[p2/64]&(1<<(p2%64)) != 0
+}
+
+func (b *bloomFilter) clear() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+}
@@ -0,0 +1,502 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 23:02:47
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 23:02:47
+ * Description: 内存缓存追加日志(AOF)持久化
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AOFSyncPolicy 追加日志的刷盘策略
+type AOFSyncPolicy string
+
+const (
+	AOFSyncAlways   AOFSyncPolicy = "always"   // 每次写操作返回前刷盘，最安全也最慢
+	AOFSyncEverySec AOFSyncPolicy = "everysec" // 每秒刷盘一次，崩溃时最多丢失约 1 秒的写入
+	AOFSyncNo       AOFSyncPolicy = "no"       // 每秒写入操作系统缓冲，由操作系统决定何时落盘
+
+	defaultAOFSync        = AOFSyncEverySec
+	defaultAOFRewriteSize = 64 << 20 // 日志超过该字节数时自动压缩
+	aofSyncInterval       = time.Second
+	aofSnapshotSuffix     = ".snap" // 压缩生成的快照文件后缀，启动时先加载快照再重放日志
+)
+
+// 日志记录类型，每条记录都是某个键写入后的完整状态，重复重放结果不变
+const (
+	aofHeaderSize = 8 // 记录头: 4 字节载荷长度 + 4 字节 CRC32
+
+	aofOpSet     byte = 1 // 普通键写入
+	aofOpDel     byte = 2 // 普通键删除
+	aofOpHashSet byte = 3 // 哈希表写入(全部字段)
+	aofOpHashDel byte = 4 // 哈希表删除
+)
+
+// appendLog 追加日志，记录格式: [4 字节载荷长度][4 字节 CRC32][载荷]
+// 写入在分片锁内进行，保证同一个键的记录顺序与内存中的修改顺序一致
+type appendLog struct {
+	mu     sync.Mutex
+	path   string
+	policy AOFSyncPolicy
+	file   *os.File
+	w      *bufio.Writer
+	size   int64 // 日志字节数(含缓冲中未写入文件的部分)
+	dirty  bool  // 上次刷盘后是否有新记录
+	err    error // 写入失败后的错误，后续写操作直接返回该错误
+	closed bool
+}
+
+// openAppendLog 以追加方式打开日志文件
+func openAppendLog(path string, policy AOFSyncPolicy) (*appendLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open aof %s failed: %w", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat aof %s failed: %w", path, err)
+	}
+	return &appendLog{path: path, policy: policy, file: f, w: bufio.NewWriter(f), size: info.Size()}, nil
+}
+
+// append 追加一条记录，调用方需持有键所在分片的写锁
+func (l *appendLog) append(entry *snapshotEntry) {
+	record := frameAOFRecord(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil || l.closed {
+		return
+	}
+	if _, err := l.w.Write(record); err != nil {
+		l.err = fmt.Errorf("write aof failed: %w", err)
+		return
+	}
+	l.size += int64(len(record))
+	l.dirty = true
+}
+
+// commit 写操作返回前调用，always 策略下刷盘，返回此前的写入错误
+func (l *appendLog) commit() error {
+	if l.policy == AOFSyncAlways {
+		return l.sync(true)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// sync 将缓冲写入文件，fsync 为 true 时同步到磁盘
+func (l *appendLog) sync(fsync bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.syncLocked(fsync)
+}
+
+func (l *appendLog) syncLocked(fsync bool) error {
+	if l.err != nil || l.closed || !l.dirty {
+		return l.err
+	}
+	if err := l.w.Flush(); err != nil {
+		l.err = fmt.Errorf("flush aof failed: %w", err)
+		return l.err
+	}
+	if fsync {
+		if err := l.file.Sync(); err != nil {
+			l.err = fmt.Errorf("fsync aof failed: %w", err)
+			return l.err
+		}
+		l.dirty = false
+	}
+	return nil
+}
+
+// mark 刷盘并返回当前日志长度，压缩时此前的记录都已包含在随后生成的快照中
+func (l *appendLog) mark() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dirty = true
+	if err := l.syncLocked(true); err != nil {
+		return 0, err
+	}
+	return l.size, nil
+}
+
+// rewriteFrom 只保留 offset 之后的记录：复制到临时文件后原子替换日志，替换期间阻塞写入
+func (l *appendLog) rewriteFrom(offset int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return fmt.Errorf("aof %s closed", l.path)
+	}
+	l.dirty = true
+	if err := l.syncLocked(true); err != nil {
+		return err
+	}
+
+	src, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("open aof %s failed: %w", l.path, err)
+	}
+	defer src.Close()
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek aof %s failed: %w", l.path, err)
+	}
+
+	dir, base := filepath.Split(l.path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create aof file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后为空操作
+
+	n, err := io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("rewrite aof %s failed: %w", l.path, err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("replace aof %s failed: %w", l.path, err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		l.err = fmt.Errorf("reopen aof %s failed: %w", l.path, err)
+		return l.err
+	}
+	l.file.Close()
+	l.file, l.w, l.size = f, bufio.NewWriter(f), n
+	return nil
+}
+
+// currentSize 当前日志长度
+func (l *appendLog) currentSize() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// close 刷盘并关闭日志文件
+func (l *appendLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.dirty = true
+	err := l.syncLocked(true)
+	l.closed = true
+	if closeErr := l.file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("close aof failed: %w", closeErr)
+	}
+	return err
+}
+
+// replayAppendLog 依次读取日志记录，末尾不完整或校验失败的记录视为崩溃时未写完，截断后继续使用
+// 校验通过但无法解码的记录返回错误，不截断文件
+// apply 同时收到记录在文件中的偏移与长度(含记录头)
+func replayAppendLog(path string, apply func(entry *snapshotEntry, offset, size int64) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open aof %s failed: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat aof %s failed: %w", path, err)
+	}
+
+	r := bufio.NewReader(f)
+	var valid int64
+	var header [aofHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			break
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		if valid+aofHeaderSize+length > info.Size() {
+			break // 长度超出文件，记录头已损坏
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		// 校验通过却无法解码的记录不是写了一半，可能来自更新的版本，截断会丢掉其后的全部记录
+		entry, err := decodeAOFRecord(payload)
+		if err != nil {
+			return fmt.Errorf("decode aof %s record at offset %d failed: %w", path, valid, err)
+		}
+		size := int64(aofHeaderSize + len(payload))
+		if err := apply(entry, valid, size); err != nil {
+			return fmt.Errorf("replay aof %s failed: %w", path, err)
+		}
+		valid += size
+	}
+
+	if err := os.Truncate(path, valid); err != nil {
+		return fmt.Errorf("truncate aof %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// frameAOFRecord 编码完整的记录：记录头与载荷
+func frameAOFRecord(entry *snapshotEntry) []byte {
+	payload := encodeAOFRecord(entry)
+	record := make([]byte, aofHeaderSize, aofHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	return append(record, payload...)
+}
+
+// parseAOFRecord 校验并解码完整的记录
+func parseAOFRecord(record []byte) (*snapshotEntry, error) {
+	if len(record) < aofHeaderSize || int(binary.BigEndian.Uint32(record[:4])) != len(record)-aofHeaderSize {
+		return nil, fmt.Errorf("truncated aof record")
+	}
+	payload := record[aofHeaderSize:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(record[4:]) {
+		return nil, fmt.Errorf("aof record checksum mismatch")
+	}
+	return decodeAOFRecord(payload)
+}
+
+// encodeAOFRecord 编码记录载荷: [类型][键][过期时间][值或哈希表字段]
+func encodeAOFRecord(entry *snapshotEntry) []byte {
+	op := aofOpSet
+	switch {
+	case entry.IsHash && entry.Deleted:
+		op = aofOpHashDel
+	case entry.IsHash:
+		op = aofOpHashSet
+	case entry.Deleted:
+		op = aofOpDel
+	}
+
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64*2+len(entry.Key)+len(entry.Value))
+	buf = append(buf, op)
+	buf = appendAOFBytes(buf, []byte(entry.Key))
+	buf = binary.AppendVarint(buf, entry.ExpiresAt)
+	switch op {
+	case aofOpSet:
+		buf = appendAOFBytes(buf, entry.Value)
+	case aofOpHashSet:
+		buf = binary.AppendUvarint(buf, uint64(len(entry.Hash)))
+		for field, marked := range entry.Hash {
+			buf = appendAOFBytes(buf, []byte(field))
+			buf = appendAOFBytes(buf, []byte(marked))
+		}
+	}
+	return buf
+}
+
+// decodeAOFRecord 解码记录载荷
+func decodeAOFRecord(payload []byte) (*snapshotEntry, error) {
+	d := aofDecoder{buf: payload}
+	op := d.byte()
+	entry := &snapshotEntry{Key: string(d.bytes()), ExpiresAt: d.varint()}
+	switch op {
+	case aofOpSet:
+		entry.Value = d.bytes()
+	case aofOpDel:
+		entry.Deleted = true
+	case aofOpHashSet:
+		entry.IsHash = true
+		n := d.uvarint()
+		if n > uint64(len(payload)) {
+			return nil, fmt.Errorf("invalid aof hash field count: %d", n)
+		}
+		entry.Hash = make(map[string]string, n)
+		for i := uint64(0); i < n; i++ {
+			field := string(d.bytes())
+			entry.Hash[field] = string(d.bytes())
+		}
+	case aofOpHashDel:
+		entry.IsHash, entry.Deleted = true, true
+	default:
+		return nil, fmt.Errorf("unknown aof record type: %d", op)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return entry, nil
+}
+
+// appendAOFBytes 追加带长度前缀的字节串
+func appendAOFBytes(buf, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// aofDecoder 记录载荷读取器，出错后的读取返回零值，由调用方最后检查 err
+type aofDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *aofDecoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("truncated aof record")
+	}
+	d.buf = nil
+}
+
+func (d *aofDecoder) byte() byte {
+	if len(d.buf) < 1 {
+		d.fail()
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *aofDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *aofDecoder) varint() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *aofDecoder) bytes() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail()
+		return nil
+	}
+	data := append([]byte(nil), d.buf[:n]...)
+	d.buf = d.buf[n:]
+	return data
+}
+
+// startAOF 加载压缩快照并重放日志，之后的写操作追加到日志，并启动后台刷盘与压缩协程
+func (m *MemoryCache) startAOF(config *CacheConfig) error {
+	if err := m.loadSnapshotFile(config.AOFPath + aofSnapshotSuffix); err != nil {
+		return err
+	}
+	if err := replayAppendLog(config.AOFPath, m.replay); err != nil {
+		return err
+	}
+
+	policy := AOFSyncPolicy(config.AOFSync)
+	if policy == "" {
+		policy = defaultAOFSync
+	}
+	log, err := openAppendLog(config.AOFPath, policy)
+	if err != nil {
+		return err
+	}
+	m.aof = log
+	for _, shard := range m.shards {
+		shard.log = log
+		shard.logCodec = m.codec
+	}
+
+	rewriteSize := config.AOFRewriteSize
+	if rewriteSize == 0 {
+		rewriteSize = defaultAOFRewriteSize
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(aofSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = log.sync(policy != AOFSyncNo) // 失败后写操作会返回该错误
+				if log.currentSize() > rewriteSize {
+					_ = m.CompactAOF() // 失败时保留原日志，下个周期重试
+				}
+			case <-m.stopChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// replay 应用一条日志记录，日志中的过期时间为绝对时间，已过期的记录等同于删除
+func (m *MemoryCache) replay(entry *snapshotEntry, _, _ int64) error {
+	shard := m.shard(entry.Key)
+	var expiresAt time.Time
+	if entry.ExpiresAt > 0 {
+		expiresAt = time.Unix(0, entry.ExpiresAt)
+	}
+	if entry.Deleted || (!expiresAt.IsZero() && !expiresAt.After(time.Now())) {
+		if entry.IsHash {
+			shard.deleteHash(entry.Key)
+		} else {
+			shard.removeItem(entry.Key, EvictReasonDeleted)
+		}
+		return nil
+	}
+	return shard.restore(entry, expiresAt, m.codec)
+}
+
+// CompactAOF 将当前数据写入压缩快照并丢弃快照已包含的日志记录，日志超过 AOFRewriteSize 时自动执行
+// 压缩期间写操作照常进行：新记录同时存在于快照与保留的日志中，重放结果不变
+func (m *MemoryCache) CompactAOF() error {
+	if m.aof == nil {
+		return fmt.Errorf("aof is not enabled")
+	}
+	m.compactMu.Lock()
+	defer m.compactMu.Unlock()
+
+	offset, err := m.aof.mark()
+	if err != nil {
+		return err
+	}
+	if err := m.saveSnapshotFile(m.aof.path + aofSnapshotSuffix); err != nil {
+		return err
+	}
+	return m.aof.rewriteFrom(offset)
+}
+
+// commit 写操作返回前提交追加日志，未启用日志时原样返回 err
+func (m *MemoryCache) commit(err error) error {
+	if err != nil || m.aof == nil {
+		return err
+	}
+	return m.aof.commit()
+}
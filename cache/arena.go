@@ -0,0 +1,806 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/19 14:20:18
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/19 14:20:18
+ * Description: 基于预分配字节环形缓冲区的内存缓存实现
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultArenaSize = 64 << 20 // 默认总容量，按分片平均分配
+	maxArenaShard    = math.MaxUint32
+
+	// 记录头部: [总长度 4][类型 1][键长度 2][过期时间 8][键哈希 8]，之后为键与载荷
+	arenaHeaderSize = 23
+
+	arenaKindValue byte = 0
+	arenaKindHash  byte = 1
+)
+
+// ArenaCache 字节数组缓存实现
+// 每个分片预先分配一块字节数组作为环形缓冲区，条目序列化后顺序写入，索引只保存键哈希到偏移量的映射。
+// 索引与缓冲区都不含指针，垃圾回收无需扫描其中的条目，适合条目数达到数百万的场景。
+// 空间不足时从最早写入的记录开始淘汰(FIFO)，覆盖与删除留下的旧记录在环形缓冲区转回时回收。
+type ArenaCache struct {
+	shards     []*arenaShard
+	defaultExp time.Duration
+	encoder    *valueEncoder
+	hashCodec  HashCodec
+	events     eventHub
+	stats      statsRecorder
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// arenaShard 缓存分片，live 区间在未回绕时为 [head, tail)，回绕后为 [head, end) 与 [0, tail)
+type arenaShard struct {
+	mu      sync.RWMutex
+	buf     []byte
+	items   map[uint64]uint32 // 普通键哈希 -> 记录偏移量
+	hashes  map[uint64]uint32 // 哈希表键哈希 -> 记录偏移量
+	head    int
+	tail    int
+	end     int
+	wrapped bool
+}
+
+// arenaVictim 被淘汰或删除的记录，在释放锁后触发事件，载荷为副本，没有监听器时为 nil
+type arenaVictim struct {
+	key     string
+	kind    byte
+	payload []byte
+	reason  EvictReason
+}
+
+// NewArenaCache 创建字节数组缓存实例，ArenaSize 为全部分片的总字节数
+func NewArenaCache(config *CacheConfig) (*ArenaCache, error) {
+	encoder, err := newValueEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+	shardCount := config.ShardCount
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	size := config.ArenaSize
+	if size <= 0 {
+		size = defaultArenaSize
+	}
+	shardSize := (size + int64(shardCount) - 1) / int64(shardCount)
+	if shardSize > maxArenaShard {
+		return nil, fmt.Errorf("arena shard size %d exceeds limit %d", shardSize, int64(maxArenaShard))
+	}
+
+	a := &ArenaCache{
+		shards:     make([]*arenaShard, shardCount),
+		defaultExp: config.DefaultExp,
+		encoder:    encoder,
+		hashCodec:  HashCodec{Strict: config.StrictHash},
+		stopChan:   make(chan struct{}),
+	}
+	for i := range a.shards {
+		a.shards[i] = &arenaShard{
+			buf:    make([]byte, shardSize),
+			items:  make(map[uint64]uint32),
+			hashes: make(map[uint64]uint32),
+		}
+	}
+
+	cleanup := config.CleanupInt
+	if cleanup <= 0 {
+		cleanup = defaultCleanupInterval
+	}
+	a.wg.Add(1)
+	go a.cleanup(cleanup)
+	return a, nil
+}
+
+// arenaHash 计算键的 64 位 FNV-1a 哈希
+func arenaHash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// shard 按键哈希选择分片
+func (a *ArenaCache) shard(h uint64) *arenaShard {
+	return a.shards[h%uint64(len(a.shards))]
+}
+
+// cleanup 定期移除过期记录的索引并触发过期事件
+func (a *ArenaCache) cleanup(interval time.Duration) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			listen := a.events.hasListeners()
+			for _, s := range a.shards {
+				s.mu.Lock()
+				victims := s.deleteExpired(time.Now().UnixNano(), listen)
+				s.mu.Unlock()
+				a.emit(victims)
+			}
+		case <-a.stopChan:
+			return
+		}
+	}
+}
+
+// emit 解码被移除的记录并触发事件
+func (a *ArenaCache) emit(victims []arenaVictim) {
+	for _, v := range victims {
+		ev := EvictionEvent{Key: v.key, IsHash: v.kind == arenaKindHash, Reason: v.reason}
+		switch {
+		case v.payload == nil:
+		case ev.IsHash:
+			if fields, err := decodeArenaHash(v.payload); err == nil {
+				ev.Value, _ = a.decodeHash(fields)
+			}
+		default:
+			ev.Value, _ = a.encoder.decode(v.payload)
+		}
+		a.events.emit(ev)
+	}
+}
+
+// expiresAt 将调用方传入的过期时间转换为绝对时间：-1 永不过期，0 使用默认过期时间
+func (a *ArenaCache) expiresAt(expiration time.Duration) int64 {
+	if expiration == 0 {
+		expiration = a.defaultExp
+	}
+	if expiration <= 0 {
+		return 0
+	}
+	return time.Now().Add(expiration).UnixNano()
+}
+
+// read 读取未过期记录的载荷副本，缓冲区在释放锁后可能被覆盖，因此不能直接返回切片
+func (a *ArenaCache) read(kind byte, key string) ([]byte, bool) {
+	h := arenaHash(key)
+	s := a.shard(h)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	off, ok := s.find(kind, h, key, time.Now().UnixNano())
+	if !ok {
+		return nil, false
+	}
+	return append([]byte{}, s.payload(off)...), true
+}
+
+// write 写入一条记录，空间不足时淘汰最早的记录
+func (a *ArenaCache) write(kind byte, key string, payload []byte, expiresAt int64) error {
+	h := arenaHash(key)
+	s := a.shard(h)
+	listen := a.events.hasListeners()
+
+	s.mu.Lock()
+	victims, err := s.put(kind, h, key, payload, expiresAt, listen)
+	s.mu.Unlock()
+
+	a.emit(victims)
+	return err
+}
+
+// Get 获取缓存值
+func (a *ArenaCache) Get(key string) (value interface{}, found bool, err error) {
+	defer a.stats.read(opGet, time.Now(), &found, &err)
+	return a.get(key)
+}
+
+// get 获取缓存值，不计入统计，供批量读取使用
+func (a *ArenaCache) get(key string) (interface{}, bool, error) {
+	data, ok := a.read(arenaKindValue, key)
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := a.encoder.decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Set 设置缓存值
+func (a *ArenaCache) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	defer a.stats.done(opSet, time.Now(), &err)
+	data, err := a.encoder.encode(value)
+	if err != nil {
+		return err
+	}
+	return a.write(arenaKindValue, key, data, a.expiresAt(expiration))
+}
+
+// Delete 删除缓存值
+func (a *ArenaCache) Delete(key string) (err error) {
+	defer a.stats.done(opDelete, time.Now(), &err)
+	a.remove(arenaKindValue, key)
+	return nil
+}
+
+// remove 删除记录的索引，未过期的记录触发删除事件
+func (a *ArenaCache) remove(kind byte, key string) bool {
+	h := arenaHash(key)
+	s := a.shard(h)
+	listen := a.events.hasListeners()
+
+	s.mu.Lock()
+	off, ok := s.find(kind, h, key, time.Now().UnixNano())
+	var victims []arenaVictim
+	if ok {
+		delete(s.table(kind), h)
+		victims = append(victims, s.victim(off, EvictReasonDeleted, listen))
+	}
+	s.mu.Unlock()
+
+	a.emit(victims)
+	return ok
+}
+
+// encodeHash 编码哈希表字段，配置了密钥时与 Redis 一样逐字段加密
+func (a *ArenaCache) encodeHash(value map[string]interface{}) (map[string]string, error) {
+	marked, err := a.hashCodec.EncodeMap(value)
+	if err != nil {
+		return nil, err
+	}
+	if a.encoder.keyring != nil {
+		for field, str := range marked {
+			sealed, err := a.encoder.seal([]byte(str))
+			if err != nil {
+				return nil, fmt.Errorf("encrypt field %s failed: %w", field, err)
+			}
+			marked[field] = string(sealed)
+		}
+	}
+	return marked, nil
+}
+
+// openHashValue 解密哈希表字段值，配置了密钥时拒绝未加密的值
+func (a *ArenaCache) openHashValue(val string) (string, error) {
+	return a.encoder.openHashValue(val)
+}
+
+// decodeHash 解密并解码哈希表字段
+func (a *ArenaCache) decodeHash(stored map[string]string) (map[string]interface{}, error) {
+	marked := make(map[string]string, len(stored))
+	for field, val := range stored {
+		opened, err := a.openHashValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt field %s failed: %w", field, err)
+		}
+		marked[field] = opened
+	}
+	return a.hashCodec.DecodeMap(marked)
+}
+
+// readHash 读取哈希表的字段，哈希表不存在时返回错误
+func (a *ArenaCache) readHash(key string) (map[string]string, error) {
+	data, ok := a.read(arenaKindHash, key)
+	if !ok {
+		return nil, missErrorf("hash key %s not found", key)
+	}
+	return decodeArenaHash(data)
+}
+
+// SetHash 设置哈希表，整体替换已有的哈希表
+func (a *ArenaCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) (err error) {
+	defer a.stats.done(opSetHash, time.Now(), &err)
+	fields, err := a.encodeHash(value)
+	if err != nil {
+		return err
+	}
+	return a.write(arenaKindHash, key, encodeArenaHash(fields), a.expiresAt(expiration))
+}
+
+// GetHash 获取整个哈希表
+func (a *ArenaCache) GetHash(key string) (hash map[string]interface{}, err error) {
+	defer a.stats.done(opGetHash, time.Now(), &err)
+	fields, err := a.readHash(key)
+	if err != nil {
+		return nil, err
+	}
+	return a.decodeHash(fields)
+}
+
+// GetHashField 获取哈希表字段
+func (a *ArenaCache) GetHashField(key, field string) (value string, err error) {
+	defer a.stats.done(opGetHashField, time.Now(), &err)
+	fields, err := a.readHash(key)
+	if err != nil {
+		return "", err
+	}
+	val, ok := fields[field]
+	if !ok {
+		return "", missErrorf("field %s not found in hash %s", field, key)
+	}
+	return a.openHashValue(val)
+}
+
+// GetHashFieldValue 获取哈希表字段并按类型标记解码，结果与 GetHash 中该字段一致
+func (a *ArenaCache) GetHashFieldValue(key, field string) (interface{}, error) {
+	marked, err := a.GetHashField(key, field)
+	if err != nil {
+		return nil, err
+	}
+	return a.hashCodec.Decode(marked)
+}
+
+// GetHashInt 获取整数哈希字段，字段类型不符时返回 ErrTypeMismatch
+func (a *ArenaCache) GetHashInt(key, field string) (int64, error) {
+	return hashInt(a.GetHashFieldValue(key, field))
+}
+
+// GetHashFloat 获取浮点数哈希字段，整数字段同样可以读取
+func (a *ArenaCache) GetHashFloat(key, field string) (float64, error) {
+	return hashFloat(a.GetHashFieldValue(key, field))
+}
+
+// GetHashString 获取字符串哈希字段
+func (a *ArenaCache) GetHashString(key, field string) (string, error) {
+	return hashString(a.GetHashFieldValue(key, field))
+}
+
+// GetHashBool 获取布尔哈希字段
+func (a *ArenaCache) GetHashBool(key, field string) (bool, error) {
+	return hashBool(a.GetHashFieldValue(key, field))
+}
+
+// GetHashBytes 获取二进制哈希字段
+func (a *ArenaCache) GetHashBytes(key, field string) ([]byte, error) {
+	return hashBytes(a.GetHashFieldValue(key, field))
+}
+
+// modifyHash 在分片锁内读取哈希表字段并交给 modify 修改后重新写入，modify 返回 nil 表示删除哈希表
+// 哈希表不存在时 fields 为 nil
+func (a *ArenaCache) modifyHash(key string, modify func(fields map[string]string, expiresAt int64) (map[string]string, int64, error)) (bool, error) {
+	h := arenaHash(key)
+	s := a.shard(h)
+	listen := a.events.hasListeners()
+
+	s.mu.Lock()
+	var fields map[string]string
+	var expiresAt int64
+	off, ok := s.find(arenaKindHash, h, key, time.Now().UnixNano())
+	if ok {
+		var err error
+		if fields, err = decodeArenaHash(s.payload(off)); err != nil {
+			s.mu.Unlock()
+			return false, err
+		}
+		expiresAt = s.expiresAt(off)
+	}
+
+	fields, expiresAt, err := modify(fields, expiresAt)
+	var victims []arenaVictim
+	removed := err == nil && fields == nil
+	switch {
+	case err != nil:
+	case removed:
+		delete(s.hashes, h)
+	default:
+		victims, err = s.put(arenaKindHash, h, key, encodeArenaHash(fields), expiresAt, listen)
+	}
+	s.mu.Unlock()
+
+	a.emit(victims)
+	return removed, err
+}
+
+// UpdateHash 更新哈希表的部分字段，其余字段与过期时间保持不变，哈希表不存在时创建
+func (a *ArenaCache) UpdateHash(key string, value map[string]interface{}) (err error) {
+	defer a.stats.done(opUpdateHash, time.Now(), &err)
+	updates, err := a.encodeHash(value)
+	if err != nil {
+		return err
+	}
+	_, err = a.modifyHash(key, func(fields map[string]string, expiresAt int64) (map[string]string, int64, error) {
+		if fields == nil {
+			return updates, a.expiresAt(0), nil
+		}
+		for field, val := range updates {
+			fields[field] = val
+		}
+		return fields, expiresAt, nil
+	})
+	return err
+}
+
+// SetHashStruct 将结构体的导出字段写入哈希表，字段名取自 `cache:"name,omitempty"` 标签
+func (a *ArenaCache) SetHashStruct(key string, v interface{}, expiration time.Duration) error {
+	values, err := structToHash(v)
+	if err != nil {
+		return err
+	}
+	return a.SetHash(key, values, expiration)
+}
+
+// GetHashStruct 读取哈希表并写入 dst 指向的结构体，哈希表中不存在的字段保持原值
+func (a *ArenaCache) GetHashStruct(key string, dst interface{}) error {
+	values, err := a.GetHash(key)
+	if err != nil {
+		return err
+	}
+	return hashToStruct(values, dst)
+}
+
+// UpdateHashStruct 只写入结构体中指定的字段（按哈希表字段名），未指定时写入全部字段，不影响其他字段与过期时间
+func (a *ArenaCache) UpdateHashStruct(key string, v interface{}, fields ...string) error {
+	values, err := structToHash(v, fields...)
+	if err != nil {
+		return err
+	}
+	return a.UpdateHash(key, values)
+}
+
+// DelHash 删除哈希表字段，删除最后一个字段时哈希表随之删除
+func (a *ArenaCache) DelHash(key, field string) (err error) {
+	defer a.stats.done(opDelHash, time.Now(), &err)
+	removed, err := a.modifyHash(key, func(fields map[string]string, expiresAt int64) (map[string]string, int64, error) {
+		if fields == nil {
+			return nil, 0, missErrorf("hash key %s not found", key)
+		}
+		if _, ok := fields[field]; !ok {
+			return nil, 0, missErrorf("field %s not found in hash %s", field, key)
+		}
+		delete(fields, field)
+		if len(fields) == 0 {
+			return nil, 0, nil
+		}
+		return fields, expiresAt, nil
+	})
+	if err == nil && removed {
+		a.events.emit(EvictionEvent{Key: key, Value: map[string]interface{}{}, IsHash: true, Reason: EvictReasonDeleted})
+	}
+	return err
+}
+
+// ExistHash 检查哈希表字段是否存在
+func (a *ArenaCache) ExistHash(key, field string) (exists bool, err error) {
+	defer a.stats.read(opExistHash, time.Now(), &exists, &err)
+	data, ok := a.read(arenaKindHash, key)
+	if !ok {
+		return false, nil
+	}
+	fields, err := decodeArenaHash(data)
+	if err != nil {
+		return false, err
+	}
+	_, ok = fields[field]
+	return ok, nil
+}
+
+// ExpireHash 设置哈希表过期时间，expiration 不大于 0 时永不过期，直接修改记录头部而不重写记录
+func (a *ArenaCache) ExpireHash(key string, expiration time.Duration) (err error) {
+	defer a.stats.done(opExpireHash, time.Now(), &err)
+	h := arenaHash(key)
+	s := a.shard(h)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, ok := s.find(arenaKindHash, h, key, time.Now().UnixNano())
+	if !ok {
+		return missErrorf("hash key %s not found", key)
+	}
+	var expiresAt int64
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration).UnixNano()
+	}
+	binary.LittleEndian.PutUint64(s.buf[off+7:], uint64(expiresAt))
+	return nil
+}
+
+// MSet 批量设置缓存值
+func (a *ArenaCache) MSet(values map[string]interface{}, expiration time.Duration) error {
+	result, err := a.MSetBatch(values, expiration)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// MGet 批量获取缓存值
+func (a *ArenaCache) MGet(keys []string) (map[string]interface{}, error) {
+	result, err := a.MGetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return result.Values(), nil
+}
+
+// MSetBatch 批量设置缓存值，逐键返回写入结果，各键使用相同的过期时间
+func (a *ArenaCache) MSetBatch(values map[string]interface{}, expiration time.Duration) (result BatchResult, err error) {
+	defer a.stats.batch(opMSet, time.Now(), &result, &err)
+	result = make(BatchResult, len(values))
+	expiresAt := a.expiresAt(expiration)
+	for key, value := range values {
+		data, err := a.encoder.encode(value)
+		if err == nil {
+			err = a.write(arenaKindValue, key, data, expiresAt)
+		}
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		result[key] = BatchItem{Status: BatchOK}
+	}
+	return result, nil
+}
+
+// MGetBatch 批量获取缓存值，逐键返回命中、未命中状态
+func (a *ArenaCache) MGetBatch(keys []string) (result BatchResult, err error) {
+	defer a.stats.batch(opMGet, time.Now(), &result, &err)
+	result = make(BatchResult, len(keys))
+	for _, key := range keys {
+		val, found, err := a.get(key)
+		switch {
+		case err != nil:
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		case !found:
+			result[key] = BatchItem{Status: BatchMiss}
+		default:
+			result[key] = BatchItem{Status: BatchOK, Value: val}
+		}
+	}
+	return result, nil
+}
+
+// GetInto 获取缓存值并直接解码到 dst 指向的变量
+func (a *ArenaCache) GetInto(key string, dst interface{}) (found bool, err error) {
+	defer a.stats.read(opGet, time.Now(), &found, &err)
+	return a.getInto(key, dst)
+}
+
+// getInto 获取缓存值并解码到 dst，不计入统计，供批量读取使用
+func (a *ArenaCache) getInto(key string, dst interface{}) (bool, error) {
+	data, ok := a.read(arenaKindValue, key)
+	if !ok {
+		return false, nil
+	}
+	return true, a.encoder.decodeInto(data, dst)
+}
+
+// MGetInto 批量获取缓存值，newDst 为每个键创建目标指针，命中时 BatchItem.Value 为该指针
+func (a *ArenaCache) MGetInto(keys []string, newDst func(key string) interface{}) (result BatchResult, err error) {
+	defer a.stats.batch(opMGet, time.Now(), &result, &err)
+	result = make(BatchResult, len(keys))
+	for _, key := range keys {
+		dst := newDst(key)
+		found, err := a.getInto(key, dst)
+		switch {
+		case err != nil:
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		case !found:
+			result[key] = BatchItem{Status: BatchMiss}
+		default:
+			result[key] = BatchItem{Status: BatchOK, Value: dst}
+		}
+	}
+	return result, nil
+}
+
+// Stats 返回统计信息，键数量包含已过期但尚未被清理的条目
+func (a *ArenaCache) Stats() Stats {
+	stats := a.stats.snapshot(CacheTypeArena, &a.events)
+	for _, shard := range a.shards {
+		shard.mu.RLock()
+		stats.Items += len(shard.items)
+		stats.Hashes += len(shard.hashes)
+		shard.mu.RUnlock()
+	}
+	return stats
+}
+
+// OnEvict 注册删除及淘汰事件监听器（Delete 删除键、DelHash 删空哈希表、空间不足淘汰）
+func (a *ArenaCache) OnEvict(listener EvictionListener) {
+	a.events.onEvict(listener)
+}
+
+// OnExpire 注册过期事件监听器，在清理协程或淘汰时移除过期记录时触发
+func (a *ArenaCache) OnExpire(listener EvictionListener) {
+	a.events.onExpire(listener)
+}
+
+// Close 关闭缓存，停止清理协程
+func (a *ArenaCache) Close() error {
+	select {
+	case <-a.stopChan:
+		return fmt.Errorf("arena cache already closed")
+	default:
+		close(a.stopChan)
+	}
+	a.wg.Wait()
+	return nil
+}
+
+// table 返回普通键或哈希表的索引
+func (s *arenaShard) table(kind byte) map[uint64]uint32 {
+	if kind == arenaKindHash {
+		return s.hashes
+	}
+	return s.items
+}
+
+// entrySize 记录总长度
+func (s *arenaShard) entrySize(off int) int {
+	return int(binary.LittleEndian.Uint32(s.buf[off:]))
+}
+
+// key 记录的键
+func (s *arenaShard) key(off uint32) []byte {
+	n := uint32(binary.LittleEndian.Uint16(s.buf[off+5:]))
+	start := off + arenaHeaderSize
+	return s.buf[start : start+n]
+}
+
+// expiresAt 记录的绝对过期时间(UnixNano)，0 表示永不过期
+func (s *arenaShard) expiresAt(off uint32) int64 {
+	return int64(binary.LittleEndian.Uint64(s.buf[off+7:]))
+}
+
+// payload 记录的载荷，返回的切片指向缓冲区，只能在持有锁时使用
+func (s *arenaShard) payload(off uint32) []byte {
+	start := off + arenaHeaderSize + uint32(binary.LittleEndian.Uint16(s.buf[off+5:]))
+	return s.buf[start : off+uint32(s.entrySize(int(off)))]
+}
+
+// find 查找未过期的记录，键哈希冲突时比较完整的键，调用方需持有锁
+func (s *arenaShard) find(kind byte, h uint64, key string, now int64) (uint32, bool) {
+	off, ok := s.table(kind)[h]
+	if !ok || string(s.key(off)) != key {
+		return 0, false
+	}
+	if expiresAt := s.expiresAt(off); expiresAt > 0 && now >= expiresAt {
+		return 0, false
+	}
+	return off, true
+}
+
+// victim 复制记录用于触发事件，withPayload 为 false 时不复制载荷
+func (s *arenaShard) victim(off uint32, reason EvictReason, withPayload bool) arenaVictim {
+	v := arenaVictim{key: string(s.key(off)), kind: s.buf[off+4], reason: reason}
+	if withPayload {
+		v.payload = append([]byte{}, s.payload(off)...)
+	}
+	return v
+}
+
+// put 写入一条记录并更新索引，同一键的旧记录成为失效记录，哈希冲突的其他键被淘汰，调用方需持有写锁
+func (s *arenaShard) put(kind byte, h uint64, key string, payload []byte, expiresAt int64, listen bool) ([]arenaVictim, error) {
+	if len(key) > math.MaxUint16 {
+		return nil, fmt.Errorf("arena key too long: %d bytes", len(key))
+	}
+	n := arenaHeaderSize + len(key) + len(payload)
+	if n > len(s.buf) {
+		return nil, fmt.Errorf("arena entry %s of %d bytes exceeds shard size %d", key, n, len(s.buf))
+	}
+
+	table := s.table(kind)
+	var victims []arenaVictim
+	if old, ok := table[h]; ok && string(s.key(old)) != key {
+		// 索引只按键哈希保存，哈希冲突的另一个键会被顶替，按淘汰处理以便触发事件并计入统计
+		reason := EvictReasonCapacity
+		if expiresAt := s.expiresAt(old); expiresAt > 0 && time.Now().UnixNano() >= expiresAt {
+			reason = EvictReasonExpired
+		}
+		victims = append(victims, s.victim(old, reason, listen))
+	}
+	delete(table, h) // 先移除旧记录的索引，避免腾出空间时把它当作有效记录淘汰
+	victims = append(victims, s.reserve(n, listen)...)
+
+	off := s.tail
+	rec := s.buf[off : off+n]
+	binary.LittleEndian.PutUint32(rec[0:], uint32(n))
+	rec[4] = kind
+	binary.LittleEndian.PutUint16(rec[5:], uint16(len(key)))
+	binary.LittleEndian.PutUint64(rec[7:], uint64(expiresAt))
+	binary.LittleEndian.PutUint64(rec[15:], h)
+	copy(rec[arenaHeaderSize:], key)
+	copy(rec[arenaHeaderSize+len(key):], payload)
+
+	s.tail += n
+	table[h] = uint32(off)
+	return victims, nil
+}
+
+// reserve 保证 tail 处有 n 字节连续空间，记录不跨越缓冲区末尾，末尾剩余空间不足时回绕到开头
+func (s *arenaShard) reserve(n int, listen bool) []arenaVictim {
+	var victims []arenaVictim
+	now := time.Now().UnixNano()
+	for {
+		if !s.wrapped {
+			if len(s.buf)-s.tail >= n {
+				return victims
+			}
+			s.end, s.tail, s.wrapped = s.tail, 0, true
+			continue
+		}
+		if s.head-s.tail >= n {
+			return victims
+		}
+		victims = s.evictHead(victims, now, listen)
+	}
+}
+
+// evictHead 淘汰最早写入的一条记录，仍被索引引用的记录触发淘汰或过期事件
+func (s *arenaShard) evictHead(victims []arenaVictim, now int64, listen bool) []arenaVictim {
+	if s.head < s.end {
+		off := uint32(s.head)
+		kind := s.buf[off+4]
+		h := binary.LittleEndian.Uint64(s.buf[off+15:])
+		table := s.table(kind)
+		if cur, ok := table[h]; ok && cur == off {
+			delete(table, h)
+			reason := EvictReasonCapacity
+			if expiresAt := s.expiresAt(off); expiresAt > 0 && now >= expiresAt {
+				reason = EvictReasonExpired
+			}
+			victims = append(victims, s.victim(off, reason, listen))
+		}
+		s.head += s.entrySize(s.head)
+	}
+	if s.head >= s.end {
+		s.head, s.wrapped = 0, false
+	}
+	return victims
+}
+
+// deleteExpired 移除过期记录的索引，记录占用的空间在环形缓冲区转回时回收
+func (s *arenaShard) deleteExpired(now int64, listen bool) []arenaVictim {
+	var victims []arenaVictim
+	for _, table := range []map[uint64]uint32{s.items, s.hashes} {
+		for h, off := range table {
+			if expiresAt := s.expiresAt(off); expiresAt == 0 || now < expiresAt {
+				continue
+			}
+			delete(table, h)
+			victims = append(victims, s.victim(off, EvictReasonExpired, listen))
+		}
+	}
+	return victims
+}
+
+// encodeArenaHash 编码哈希表字段: [字段数][字段名][字段值]...
+func encodeArenaHash(fields map[string]string) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(fields)))
+	for field, val := range fields {
+		buf = appendAOFBytes(buf, []byte(field))
+		buf = appendAOFBytes(buf, []byte(val))
+	}
+	return buf
+}
+
+// decodeArenaHash 解码哈希表字段
+func decodeArenaHash(data []byte) (map[string]string, error) {
+	d := aofDecoder{buf: data}
+	n := d.uvarint()
+	if n > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid arena hash field count: %d", n)
+	}
+	fields := make(map[string]string, n)
+	for i := uint64(0); i < n; i++ {
+		field := string(d.bytes())
+		fields[field] = string(d.bytes())
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return fields, nil
+}
@@ -0,0 +1,236 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 18:42:09
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 18:42:09
+ * Description: 缓存值编解码器
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+const (
+	CodecJSON = "json" // 默认，与历史数据兼容，数字解码为 float64
+	CodecGob  = "gob"  // 保留 Go 类型，自定义类型需先 gob.Register
+	CodecRaw  = "raw"  // 仅支持 []byte 与 string，原样存储
+)
+
+// ErrTypeMismatch 缓存中的值无法赋给 GetInto/MGetInto 的目标类型
+var ErrTypeMismatch = errors.New("type mismatch")
+
+func init() {
+	// 常用的非基础类型，便于 interface{} 中的值直接使用 gob 编码
+	gob.Register(time.Time{})
+	gob.Register(time.Duration(0))
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// Codec 缓存值编解码器，用于 Redis 存储及内存缓存的序列化存储模式
+type Codec interface {
+	// Name 编解码器名称，用于错误信息
+	Name() string
+	// Marshal 将值编码为字节
+	Marshal(value interface{}) ([]byte, error)
+	// Unmarshal 将字节解码到 dst 指向的变量，dst 为 *interface{} 时解码为编解码器的默认类型
+	Unmarshal(data []byte, dst interface{}) error
+}
+
+// newCodec 按配置创建编解码器，WithCodec 指定的实例优先于名称
+func newCodec(config *CacheConfig) (Codec, error) {
+	if config.ValueCodec != nil {
+		return config.ValueCodec, nil
+	}
+	switch config.Codec {
+	case CodecJSON, "":
+		return JSONCodec{}, nil
+	case CodecGob:
+		return GobCodec{}, nil
+	case CodecRaw:
+		return RawCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec: %s", config.Codec)
+	}
+}
+
+// marshalValue 使用编解码器编码值，错误信息带上编解码器名称
+func marshalValue(codec Codec, value interface{}) ([]byte, error) {
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s marshal failed: %w", codec.Name(), err)
+	}
+	return data, nil
+}
+
+// unmarshalValue 使用编解码器解码值，错误信息带上编解码器名称
+func unmarshalValue(codec Codec, data []byte) (interface{}, error) {
+	var value interface{}
+	if err := unmarshalInto(codec, data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// unmarshalInto 使用编解码器解码到 dst，错误信息带上编解码器名称
+func unmarshalInto(codec Codec, data []byte, dst interface{}) error {
+	if err := codec.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("%s unmarshal failed: %w", codec.Name(), err)
+	}
+	return nil
+}
+
+// JSONCodec JSON 编解码器
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return CodecJSON }
+
+func (JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Unmarshal(data []byte, dst interface{}) error {
+	return json.Unmarshal(data, dst)
+}
+
+// GobCodec gob 编解码器，值以接口形式编码，解码时还原为原始 Go 类型
+type GobCodec struct{}
+
+func (GobCodec) Name() string { return CodecGob }
+
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, dst interface{}) error {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return err
+	}
+	return assignValue(dst, value)
+}
+
+// RawCodec 原样存储 []byte 与 string，解码为 []byte
+type RawCodec struct{}
+
+func (RawCodec) Name() string { return CodecRaw }
+
+func (RawCodec) Marshal(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("raw codec only supports []byte and string, got %T", value)
+	}
+}
+
+func (RawCodec) Unmarshal(data []byte, dst interface{}) error {
+	switch d := dst.(type) {
+	case *[]byte:
+		*d = append([]byte(nil), data...)
+	case *string:
+		*d = string(data)
+	default:
+		return assignValue(dst, append([]byte(nil), data...))
+	}
+	return nil
+}
+
+// assignValue 将解码得到的值赋给 dst 指向的变量，数值类型之间可相互转换(溢出或丢失小数时返回 ErrTypeMismatch)，其他不兼容类型返回 ErrTypeMismatch
+func assignValue(dst, value interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer, got %T", dst)
+	}
+	elem := rv.Elem()
+	if value == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+
+	src := reflect.ValueOf(value)
+	switch {
+	case src.Type().AssignableTo(elem.Type()):
+		elem.Set(src)
+	case isNumberKind(src.Kind()) && isNumberKind(elem.Kind()):
+		converted, err := convertNumber(src, elem.Type())
+		if err != nil {
+			return err
+		}
+		elem.Set(converted)
+	default:
+		return fmt.Errorf("%w: cannot assign %T to %s", ErrTypeMismatch, value, elem.Type())
+	}
+	return nil
+}
+
+// isNumberKind 是否为整数或浮点数类型
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+// convertNumber 将数值转换为目标数值类型，超出目标类型范围、负数转为无符号整数或浮点数带小数转为整数时返回 ErrTypeMismatch
+func convertNumber(src reflect.Value, to reflect.Type) (reflect.Value, error) {
+	target := reflect.Zero(to)
+	ok := true
+	switch {
+	case isIntKind(to.Kind()):
+		switch {
+		case isIntKind(src.Kind()):
+			ok = !target.OverflowInt(src.Int())
+		case isUintKind(src.Kind()):
+			ok = src.Uint() <= math.MaxInt64 && !target.OverflowInt(int64(src.Uint()))
+		default:
+			f := src.Float()
+			ok = f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !target.OverflowInt(int64(f))
+		}
+	case isUintKind(to.Kind()):
+		switch {
+		case isIntKind(src.Kind()):
+			ok = src.Int() >= 0 && !target.OverflowUint(uint64(src.Int()))
+		case isUintKind(src.Kind()):
+			ok = !target.OverflowUint(src.Uint())
+		default:
+			f := src.Float()
+			ok = f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 && !target.OverflowUint(uint64(f))
+		}
+	default:
+		switch {
+		case isIntKind(src.Kind()):
+			ok = !target.OverflowFloat(float64(src.Int()))
+		case isUintKind(src.Kind()):
+			ok = !target.OverflowFloat(float64(src.Uint()))
+		default:
+			ok = !target.OverflowFloat(src.Float())
+		}
+	}
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%w: %v overflows or truncates %s", ErrTypeMismatch, src.Interface(), to)
+	}
+	return src.Convert(to), nil
+}
+
+// isIntKind 是否为有符号整数类型
+func isIntKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+// isUintKind 是否为无符号整数类型
+func isUintKind(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uintptr
+}
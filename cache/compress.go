@@ -0,0 +1,280 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 19:20:14
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 19:20:14
+ * Description: 缓存值压缩与信封格式
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const (
+	CompressionNone  = ""
+	CompressionGzip  = "gzip"
+	CompressionFlate = "flate"
+
+	defaultCompressThreshold = 1024 // 默认压缩阈值(字节)
+
+	// 信封格式: [envelopeMagic][信封类型][类型相关的头部][载荷]
+	// JSON 与 gob 编码结果的首字节都不会是 0x00，据此区分未加信封的历史数据；
+	// RawCodec 的载荷可能以 0x00 开头，这类载荷写入时加一层 envelopePlain，读取时不会被误当作信封
+	envelopeMagic      byte = 0x00
+	envelopePlain      byte = 0x00 // 无头部，载荷原样保存
+	envelopeCompressed byte = 0x01 // 头部为 1 字节压缩算法 ID
+)
+
+// Compressor 压缩算法
+type Compressor interface {
+	// ID 写入信封头部的算法标识，自定义实现应使用 128 以上的值避免与内置算法冲突
+	ID() byte
+	// Name 算法名称，用于错误信息
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// newCompressor 按配置创建压缩算法，未启用时返回 nil
+func newCompressor(config *CacheConfig) (Compressor, error) {
+	if config.Compressor != nil {
+		return config.Compressor, nil
+	}
+	switch config.Compression {
+	case CompressionNone:
+		return nil, nil
+	case CompressionGzip:
+		return GzipCompressor{Level: gzip.DefaultCompression}, nil
+	case CompressionFlate:
+		return FlateCompressor{Level: flate.DefaultCompression}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", config.Compression)
+	}
+}
+
+// GzipCompressor gzip 压缩，Level 取值同 compress/gzip
+type GzipCompressor struct {
+	Level int
+}
+
+func (GzipCompressor) ID() byte     { return 1 }
+func (GzipCompressor) Name() string { return CompressionGzip }
+
+func (c GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// FlateCompressor DEFLATE 压缩，Level 取值同 compress/flate，头部开销比 gzip 小
+type FlateCompressor struct {
+	Level int
+}
+
+func (FlateCompressor) ID() byte     { return 2 }
+func (FlateCompressor) Name() string { return CompressionFlate }
+
+func (c FlateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (FlateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// valueEncoder Redis 值的完整编码流程：编解码器序列化，超过阈值时压缩，配置密钥时加密，压缩与加密各加一层信封
+type valueEncoder struct {
+	codec      Codec
+	compressor Compressor
+	threshold  int
+	keyring    *keyring
+	allowPlain bool // 配置了密钥时仍接受未加密的值
+}
+
+// newValueEncoder 按配置创建值编码器
+func newValueEncoder(config *CacheConfig) (*valueEncoder, error) {
+	codec, err := newCodec(config)
+	if err != nil {
+		return nil, err
+	}
+	compressor, err := newCompressor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := newKeyring(config)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := config.CompressThreshold
+	if threshold <= 0 {
+		threshold = defaultCompressThreshold
+	}
+	return &valueEncoder{codec: codec, compressor: compressor, threshold: threshold, keyring: keyring, allowPlain: config.AllowPlaintext}, nil
+}
+
+// encode 编码值
+func (e *valueEncoder) encode(value interface{}) ([]byte, error) {
+	data, err := marshalValue(e.codec, value)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = e.compress(data); err != nil {
+		return nil, err
+	}
+	return e.seal(data)
+}
+
+// compress 未启用压缩、未超过阈值或压缩后没有变小时原样返回
+func (e *valueEncoder) compress(data []byte) ([]byte, error) {
+	if e.compressor == nil || len(data) < e.threshold {
+		return escapePlain(data), nil
+	}
+
+	compressed, err := e.compressor.Compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s compress failed: %w", e.compressor.Name(), err)
+	}
+	if len(compressed)+3 >= len(data) {
+		return escapePlain(data), nil
+	}
+	return append([]byte{envelopeMagic, envelopeCompressed, e.compressor.ID()}, compressed...), nil
+}
+
+// escapePlain 以 envelopeMagic 开头的未压缩载荷加一层 envelopePlain，其他载荷原样返回
+func escapePlain(data []byte) []byte {
+	if len(data) == 0 || data[0] != envelopeMagic {
+		return data
+	}
+	return append([]byte{envelopeMagic, envelopePlain}, data...)
+}
+
+// seal 配置了密钥时加密，否则原样返回
+func (e *valueEncoder) seal(data []byte) ([]byte, error) {
+	if e.keyring == nil {
+		return data, nil
+	}
+	return e.keyring.seal(data)
+}
+
+// decode 解码值，自动识别信封，未加信封的历史数据直接交给编解码器
+func (e *valueEncoder) decode(data []byte) (interface{}, error) {
+	data, err := e.open(data)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalValue(e.codec, data)
+}
+
+// decodeInto 解码值到 dst 指向的变量
+func (e *valueEncoder) decodeInto(data []byte, dst interface{}) error {
+	data, err := e.open(data)
+	if err != nil {
+		return err
+	}
+	return unmarshalInto(e.codec, data, dst)
+}
+
+// open 逐层拆开信封，返回编解码器可直接解析的字节
+// 配置了密钥时最外层必须是加密信封，除非开启了 AllowPlaintext
+func (e *valueEncoder) open(data []byte) ([]byte, error) {
+	if e.keyring != nil && !e.allowPlain && !isSealed(data) {
+		return nil, fmt.Errorf("value is not encrypted")
+	}
+	for len(data) >= 2 && data[0] == envelopeMagic {
+		switch data[1] {
+		case envelopePlain:
+			return data[2:], nil
+		case envelopeCompressed:
+			if len(data) < 3 {
+				return nil, fmt.Errorf("truncated compressed value")
+			}
+			compressor, err := e.compressorByID(data[2])
+			if err != nil {
+				return nil, err
+			}
+			// 压缩是最内层，解压后即为编解码器的字节，不能再按信封解析
+			plain, err := compressor.Decompress(data[3:])
+			if err != nil {
+				return nil, fmt.Errorf("%s decompress failed: %w", compressor.Name(), err)
+			}
+			return plain, nil
+		case envelopeEncrypted:
+			if e.keyring == nil {
+				return nil, fmt.Errorf("value is encrypted but no encryption key is configured")
+			}
+			var err error
+			if data, err = e.keyring.open(data); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown value envelope type: %d", data[1])
+		}
+	}
+	return data, nil
+}
+
+// openHashValue 解密哈希表字段值，未配置密钥且未加信封的值原样返回
+func (e *valueEncoder) openHashValue(val string) (string, error) {
+	if e.keyring == nil && (len(val) < 2 || val[0] != envelopeMagic) {
+		return val, nil
+	}
+	data, err := e.open([]byte(val))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// compressorByID 按信封中的算法 ID 查找压缩算法，未启用压缩时仍可读取内置算法压缩的数据
+func (e *valueEncoder) compressorByID(id byte) (Compressor, error) {
+	if e.compressor != nil && e.compressor.ID() == id {
+		return e.compressor, nil
+	}
+	switch id {
+	case GzipCompressor{}.ID():
+		return GzipCompressor{}, nil
+	case FlateCompressor{}.ID():
+		return FlateCompressor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown compressor id: %d", id)
+	}
+}
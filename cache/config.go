@@ -0,0 +1,290 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 18:05:36
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 18:05:36
+ * Description: 配置加载（JSON、配置文件、环境变量）与校验
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "GOSCACHE_"
+
+// NewCacheFromConfig 校验配置后创建缓存实例
+// config 可由 JSON 解码（时长写作 "5m" 等字符串）或 LoadConfigFromEnv 构造，
+// 建议在 DefaultConfig 的基础上解码以保留未指定字段的默认值
+func NewCacheFromConfig(config *CacheConfig) (CacheInterface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("cache config is nil")
+	}
+	return newCache(config)
+}
+
+// LoadConfigFile 从 JSON 配置文件加载配置，未指定的字段使用默认值
+func LoadConfigFile(path string) (*CacheConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := DefaultConfig("")
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// LoadConfigFromEnv 从 GOSCACHE_* 环境变量加载配置，未设置的字段使用默认值
+func LoadConfigFromEnv() (*CacheConfig, error) {
+	config := DefaultConfig("")
+	if err := config.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ApplyEnv 使用 GOSCACHE_* 环境变量覆盖配置，可在加载配置文件后调用
+// 变量名为 GOSCACHE_ 加上字段 json 标签的大写形式，如 GOSCACHE_POOL_SIZE、GOSCACHE_DEFAULT_EXP；
+// 时长写作 "5m" 等字符串，列表字段以逗号分隔
+func (c *CacheConfig) ApplyEnv() error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := envPrefix + strings.ToUpper(tag)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid environment variable %s=%q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+// setField 将字符串解析后写入配置字段
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate 校验配置，返回所有不合法的字段
+func (c *CacheConfig) Validate() error {
+	var errs []error
+	check := func(invalid bool, format string, args ...interface{}) {
+		if invalid {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	cacheType := CacheType(c.Type)
+	switch cacheType {
+	case CacheTypeMemory, CacheTypeRedis, CacheTypeMultiLevel, CacheTypeFile, CacheTypeArena:
+	case "":
+		errs = append(errs, fmt.Errorf("cache type is required"))
+	default:
+		errs = append(errs, fmt.Errorf("unsupported cache type: %s", c.Type))
+	}
+
+	check(c.DB < 0, "db must not be negative: %d", c.DB)
+	check(c.PoolSize < 0, "pool_size must not be negative: %d", c.PoolSize)
+	check(c.MinIdleConns < 0, "min_idle_conns must not be negative: %d", c.MinIdleConns)
+	check(c.MaxEntries < 0, "max_entries must not be negative: %d", c.MaxEntries)
+	check(c.MaxBytes < 0, "max_bytes must not be negative: %d", c.MaxBytes)
+	check(c.ShardCount < 0, "shard_count must not be negative: %d", c.ShardCount)
+	check(c.CleanupInt < 0, "cleanup_int must not be negative: %s", c.CleanupInt)
+	check(c.HashKeyExpiry < 0, "hash_key_expiry must not be negative: %s", c.HashKeyExpiry)
+	check(c.DialTimeout < 0, "dial_timeout must not be negative: %s", c.DialTimeout)
+	check(c.ReadTimeout < 0, "read_timeout must not be negative: %s", c.ReadTimeout)
+	check(c.WriteTimeout < 0, "write_timeout must not be negative: %s", c.WriteTimeout)
+	check(c.L1Expiration < 0, "l1_exp must not be negative: %s", c.L1Expiration)
+	check(c.SnapshotInterval < 0, "snapshot_interval must not be negative: %s", c.SnapshotInterval)
+	check(c.SnapshotInterval > 0 && c.SnapshotPath == "", "snapshot_interval requires snapshot_path")
+	check(c.AOFRewriteSize < 0, "aof_rewrite_size must not be negative: %d", c.AOFRewriteSize)
+	check(c.AOFPath != "" && c.AOFPath == c.SnapshotPath, "aof_path and snapshot_path must differ")
+	checkSync := func(name, policy string) {
+		switch AOFSyncPolicy(policy) {
+		case AOFSyncAlways, AOFSyncEverySec, AOFSyncNo, "":
+		default:
+			errs = append(errs, fmt.Errorf("unsupported %s sync policy: %s", name, policy))
+		}
+	}
+	checkSync("aof", c.AOFSync)
+	checkSync("file", c.FileSync)
+	check(cacheType == CacheTypeFile && c.FilePath == "", "file cache requires file_path")
+	check(c.ArenaSize < 0, "arena_size must not be negative: %d", c.ArenaSize)
+
+	if _, err := newCodec(c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newCompressor(c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newKeyring(c); err != nil {
+		errs = append(errs, err)
+	}
+	check(c.CompressThreshold < 0, "compress_threshold must not be negative: %d", c.CompressThreshold)
+
+	if cacheType != CacheTypeRedis {
+		switch EvictionPolicy(c.EvictionPolicy) {
+		case EvictionLRU, EvictionLFU, EvictionARC, "":
+		default:
+			errs = append(errs, fmt.Errorf("unsupported eviction policy: %s", c.EvictionPolicy))
+		}
+		switch AdmissionPolicy(c.Admission) {
+		case AdmissionNone:
+		case AdmissionTinyLFU:
+			check(c.MaxEntries <= 0 && c.MaxBytes <= 0, "admission policy %s requires max entries or max bytes", c.Admission)
+		default:
+			errs = append(errs, fmt.Errorf("unsupported admission policy: %s", c.Admission))
+		}
+	}
+
+	if cacheType == CacheTypeRedis || cacheType == CacheTypeMultiLevel {
+		switch RedisMode(c.Mode) {
+		case RedisModeStandalone, "":
+			check(c.URL == "", "redis url is required")
+		case RedisModeCluster:
+			check(c.URL == "" && len(c.ClusterAddrs) == 0, "redis cluster requires addresses")
+			check(c.DB != 0, "redis cluster does not support db %d", c.DB)
+		case RedisModeSentinel:
+			check(c.URL == "" && len(c.SentinelAddrs) == 0, "redis sentinel requires addresses")
+			check(c.SentinelMasterName == "", "redis sentinel requires master name")
+		default:
+			errs = append(errs, fmt.Errorf("unsupported redis mode: %s", c.Mode))
+		}
+		check((c.TLSCertFile == "") != (c.TLSKeyFile == ""), "tls cert file and key file must be set together")
+	}
+
+	return errors.Join(errs...)
+}
+
+// jsonDuration JSON 中的时长，编码为 "5m" 等字符串，解码同时兼容纳秒整数
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case float64:
+		*d = jsonDuration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = jsonDuration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+	return nil
+}
+
+// configAlias 去掉 JSON 方法的配置类型，避免编解码时递归
+type configAlias CacheConfig
+
+// configJSON 配置的 JSON 形式，时长字段覆盖为 jsonDuration
+type configJSON struct {
+	*configAlias
+	DefaultExp       jsonDuration `json:"default_exp"`
+	CleanupInt       jsonDuration `json:"cleanup_int"`
+	HashKeyExpiry    jsonDuration `json:"hash_key_expiry"`
+	DialTimeout      jsonDuration `json:"dial_timeout"`
+	ReadTimeout      jsonDuration `json:"read_timeout"`
+	WriteTimeout     jsonDuration `json:"write_timeout"`
+	L1Expiration     jsonDuration `json:"l1_exp"`
+	SnapshotInterval jsonDuration `json:"snapshot_interval"`
+}
+
+func newConfigJSON(c *CacheConfig) *configJSON {
+	return &configJSON{
+		configAlias:      (*configAlias)(c),
+		DefaultExp:       jsonDuration(c.DefaultExp),
+		CleanupInt:       jsonDuration(c.CleanupInt),
+		HashKeyExpiry:    jsonDuration(c.HashKeyExpiry),
+		DialTimeout:      jsonDuration(c.DialTimeout),
+		ReadTimeout:      jsonDuration(c.ReadTimeout),
+		WriteTimeout:     jsonDuration(c.WriteTimeout),
+		L1Expiration:     jsonDuration(c.L1Expiration),
+		SnapshotInterval: jsonDuration(c.SnapshotInterval),
+	}
+}
+
+// MarshalJSON 编码配置，时长字段输出为 "5m0s" 等字符串
+func (c CacheConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newConfigJSON(&c))
+}
+
+// UnmarshalJSON 解码配置，时长字段支持 "5m" 等字符串或纳秒整数，未出现的字段保持原值
+func (c *CacheConfig) UnmarshalJSON(data []byte) error {
+	aux := newConfigJSON(c)
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	c.DefaultExp = time.Duration(aux.DefaultExp)
+	c.CleanupInt = time.Duration(aux.CleanupInt)
+	c.HashKeyExpiry = time.Duration(aux.HashKeyExpiry)
+	c.DialTimeout = time.Duration(aux.DialTimeout)
+	c.ReadTimeout = time.Duration(aux.ReadTimeout)
+	c.WriteTimeout = time.Duration(aux.WriteTimeout)
+	c.L1Expiration = time.Duration(aux.L1Expiration)
+	c.SnapshotInterval = time.Duration(aux.SnapshotInterval)
+	return nil
+}
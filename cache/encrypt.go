@@ -0,0 +1,100 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 19:58:31
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 19:58:31
+ * Description: 缓存值 AES-GCM 加密
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// envelopeEncrypted 加密信封，头部为 1 字节密钥 ID 长度、密钥 ID 与 nonce
+const envelopeEncrypted byte = 0x02
+
+// keyring AES-GCM 密钥环，使用当前密钥加密，按信封中的密钥 ID 选择解密密钥
+type keyring struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+}
+
+// newKeyring 按配置创建密钥环，未配置密钥时返回 nil
+func newKeyring(config *CacheConfig) (*keyring, error) {
+	if len(config.EncryptionKeys) == 0 {
+		if config.EncryptionKeyID != "" {
+			return nil, fmt.Errorf("encryption key %s not found", config.EncryptionKeyID)
+		}
+		return nil, nil
+	}
+
+	k := &keyring{activeID: config.EncryptionKeyID, aeads: make(map[string]cipher.AEAD, len(config.EncryptionKeys))}
+	for id, key := range config.EncryptionKeys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("invalid encryption key id %q: length must be 1-255", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	if _, ok := k.aeads[k.activeID]; !ok {
+		return nil, fmt.Errorf("encryption key %s not found", k.activeID)
+	}
+	return k, nil
+}
+
+// isSealed 是否为加密信封
+func isSealed(data []byte) bool {
+	return len(data) >= 2 && data[0] == envelopeMagic && data[1] == envelopeEncrypted
+}
+
+// seal 使用当前密钥加密，返回完整的加密信封
+// 信封头部作为附加认证数据，篡改密钥 ID 会导致解密失败
+func (k *keyring) seal(data []byte) ([]byte, error) {
+	aead := k.aeads[k.activeID]
+	header := append([]byte{envelopeMagic, envelopeEncrypted, byte(len(k.activeID))}, k.activeID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(header)+len(nonce)+len(data)+aead.Overhead())
+	out = append(append(out, header...), nonce...)
+	return aead.Seal(out, nonce, data, header), nil
+}
+
+// open 解密完整的加密信封
+func (k *keyring) open(data []byte) ([]byte, error) {
+	if len(data) < 3 || len(data) < 3+int(data[2]) {
+		return nil, fmt.Errorf("truncated encrypted value")
+	}
+	headerLen := 3 + int(data[2])
+	id := string(data[3:headerLen])
+
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %s not found", id)
+	}
+	if len(data) < headerLen+aead.NonceSize() {
+		return nil, fmt.Errorf("truncated encrypted value")
+	}
+
+	nonce := data[headerLen : headerLen+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[headerLen+aead.NonceSize():], data[:headerLen])
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with key %s failed: %w", id, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,101 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 10:12:36
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 10:12:36
+ * Description: 缓存淘汰与过期事件
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvictReason 缓存项被移除的原因
+type EvictReason int
+
+const (
+	EvictReasonDeleted  EvictReason = iota + 1 // 显式删除
+	EvictReasonExpired                         // 过期
+	EvictReasonCapacity                        // 容量不足被淘汰
+)
+
+// String 返回原因名称
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonDeleted:
+		return "deleted"
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonCapacity:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionEvent 缓存项移除事件
+type EvictionEvent struct {
+	Key    string      // 键名（不含前缀）
+	Value  interface{} // 被移除的值，Redis 缓存无法获取时为 nil
+	IsHash bool        // 是否为哈希表，Redis 缓存无法区分时为 false
+	Reason EvictReason // 移除原因
+}
+
+// EvictionListener 缓存项移除事件回调
+// 回调在触发移除的协程中同步执行，应避免阻塞或再次调用缓存的写操作
+type EvictionListener func(EvictionEvent)
+
+// eventHub 事件监听器注册表，同时统计淘汰与过期的条目数
+type eventHub struct {
+	mu     sync.RWMutex
+	evict  []EvictionListener
+	expire []EvictionListener
+
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// onEvict 注册删除及淘汰事件监听器
+func (h *eventHub) onEvict(listener EvictionListener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.evict = append(h.evict, listener)
+}
+
+// onExpire 注册过期事件监听器
+func (h *eventHub) onExpire(listener EvictionListener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expire = append(h.expire, listener)
+}
+
+// hasListeners 是否注册了任意监听器
+func (h *eventHub) hasListeners() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.evict) > 0 || len(h.expire) > 0
+}
+
+// emit 按原因分发事件：过期事件发送给 OnExpire 监听器，其余发送给 OnEvict 监听器
+func (h *eventHub) emit(ev EvictionEvent) {
+	switch ev.Reason {
+	case EvictReasonCapacity:
+		h.evictions.Add(1)
+	case EvictReasonExpired:
+		h.expirations.Add(1)
+	}
+
+	h.mu.RLock()
+	listeners := h.evict
+	if ev.Reason == EvictReasonExpired {
+		listeners = h.expire
+	}
+	h.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(ev)
+	}
+}
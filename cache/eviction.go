@@ -0,0 +1,527 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 11:05:12
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 11:05:12
+ * Description: 内存缓存容量限制与淘汰策略
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// EvictionPolicy 容量淘汰策略
+type EvictionPolicy string
+
+const (
+	EvictionLRU EvictionPolicy = "lru" // 最近最少使用
+	EvictionLFU EvictionPolicy = "lfu" // 最不经常使用
+	EvictionARC EvictionPolicy = "arc" // 自适应替换缓存
+
+	defaultEvictionPolicy = EvictionLRU
+)
+
+// entryRef 淘汰策略中的条目标识，普通键与哈希表键可以同名，需分开记录
+type entryRef struct {
+	key  string
+	hash bool
+}
+
+// evictionPolicy 淘汰策略，只记录条目顺序，不持有数据
+type evictionPolicy interface {
+	add(ref entryRef)                         // 记录新条目
+	access(ref entryRef)                      // 记录一次访问或更新
+	remove(ref entryRef)                      // 条目被删除或过期
+	peek(exclude entryRef) (entryRef, bool)   // 返回下一个淘汰对象，不修改策略状态
+	victim(exclude entryRef) (entryRef, bool) // 选出一个淘汰对象并将其移出策略
+	len() int
+}
+
+// newEvictionPolicy 按名称创建淘汰策略
+func newEvictionPolicy(policy EvictionPolicy, maxEntries int) (evictionPolicy, error) {
+	switch policy {
+	case EvictionLRU, "":
+		return newLRUPolicy(), nil
+	case EvictionLFU:
+		return newLFUPolicy(), nil
+	case EvictionARC:
+		return newARCPolicy(maxEntries), nil
+	default:
+		return nil, fmt.Errorf("unsupported eviction policy: %s", policy)
+	}
+}
+
+// capacityLimiter 容量限制器，统计条目数与字节数并在超限时选出淘汰对象
+type capacityLimiter struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	policy     evictionPolicy
+	admission  *tinyLFU // 准入过滤器，未启用时为 nil
+	sizes      map[entryRef]int64
+	bytes      int64
+}
+
+// newCapacityLimiter 创建容量限制器，未设置任何上限时返回 nil
+func newCapacityLimiter(config *CacheConfig) (*capacityLimiter, error) {
+	if config.MaxEntries <= 0 && config.MaxBytes <= 0 {
+		if AdmissionPolicy(config.Admission) != AdmissionNone {
+			return nil, fmt.Errorf("admission policy %s requires max entries or max bytes", config.Admission)
+		}
+		return nil, nil
+	}
+
+	policy, err := newEvictionPolicy(EvictionPolicy(config.EvictionPolicy), config.MaxEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	admission, err := newAdmissionFilter(AdmissionPolicy(config.Admission), config.MaxEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &capacityLimiter{
+		maxEntries: config.MaxEntries,
+		maxBytes:   config.MaxBytes,
+		policy:     policy,
+		admission:  admission,
+		sizes:      make(map[entryRef]int64),
+	}, nil
+}
+
+// admit 记录条目写入，返回为腾出空间需要移除的条目
+// 启用准入过滤器时，需要淘汰才能写入的新条目若访问频率不高于淘汰对象则被拒绝，此时 admitted 为 false
+func (c *capacityLimiter) admit(ref entryRef, size int64) (victims []entryRef, admitted bool, err error) {
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return nil, false, fmt.Errorf("entry %s size %d exceeds max bytes %d", ref.key, size, c.maxBytes)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	oldSize, exists := c.sizes[ref]
+	count := len(c.sizes)
+	if !exists {
+		count++
+	}
+	total := c.bytes - oldSize + size
+	over := (c.maxEntries > 0 && count > c.maxEntries) || (c.maxBytes > 0 && total > c.maxBytes)
+
+	if c.admission != nil {
+		c.admission.increment(ref)
+		if !exists && over {
+			if victim, ok := c.policy.peek(ref); ok && !c.admission.admit(ref, victim) {
+				return nil, false, nil
+			}
+		}
+	}
+
+	for (c.maxEntries > 0 && count > c.maxEntries) || (c.maxBytes > 0 && total > c.maxBytes) {
+		victim, ok := c.policy.victim(ref)
+		if !ok {
+			break
+		}
+		victims = append(victims, victim)
+		total -= c.sizes[victim]
+		count--
+		delete(c.sizes, victim)
+	}
+
+	c.sizes[ref] = size
+	c.bytes = total
+	if exists {
+		c.policy.access(ref)
+	} else {
+		c.policy.add(ref)
+	}
+
+	return victims, true, nil
+}
+
+// touch 记录一次读取，未命中也会计入准入过滤器的频率统计
+func (c *capacityLimiter) touch(ref entryRef) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.admission != nil {
+		c.admission.increment(ref)
+	}
+	if _, exists := c.sizes[ref]; exists {
+		c.policy.access(ref)
+	}
+}
+
+// forget 条目被删除或过期后移除统计
+func (c *capacityLimiter) forget(ref entryRef) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if size, exists := c.sizes[ref]; exists {
+		c.bytes -= size
+		delete(c.sizes, ref)
+		c.policy.remove(ref)
+	}
+}
+
+// estimateSize 估算缓存值占用的字节数
+func estimateSize(key string, value interface{}) int64 {
+	size := int64(len(key))
+	switch v := value.(type) {
+	case nil:
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	case bool, int8, uint8:
+		size++
+	case int16, uint16:
+		size += 2
+	case int32, uint32, float32:
+		size += 4
+	case int, int64, uint, uint64, float64:
+		size += 8
+	case map[string]interface{}:
+		for field, val := range v {
+			size += estimateSize(field, val)
+		}
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			size += int64(len(data))
+		} else {
+			size += 64
+		}
+	}
+	return size
+}
+
+// lruPolicy 最近最少使用
+type lruPolicy struct {
+	ll    *list.List
+	items map[entryRef]*list.Element
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{ll: list.New(), items: make(map[entryRef]*list.Element)}
+}
+
+func (p *lruPolicy) add(ref entryRef) {
+	p.items[ref] = p.ll.PushFront(ref)
+}
+
+func (p *lruPolicy) access(ref entryRef) {
+	if e, ok := p.items[ref]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) remove(ref entryRef) {
+	if e, ok := p.items[ref]; ok {
+		p.ll.Remove(e)
+		delete(p.items, ref)
+	}
+}
+
+func (p *lruPolicy) peek(exclude entryRef) (entryRef, bool) {
+	for e := p.ll.Back(); e != nil; e = e.Prev() {
+		if ref := e.Value.(entryRef); ref != exclude {
+			return ref, true
+		}
+	}
+	return entryRef{}, false
+}
+
+func (p *lruPolicy) victim(exclude entryRef) (entryRef, bool) {
+	ref, ok := p.peek(exclude)
+	if ok {
+		p.remove(ref)
+	}
+	return ref, ok
+}
+
+func (p *lruPolicy) len() int {
+	return p.ll.Len()
+}
+
+// lfuEntry LFU 条目
+type lfuEntry struct {
+	ref  entryRef
+	freq int
+}
+
+// lfuPolicy 最不经常使用，同频率下淘汰最久未访问的条目
+type lfuPolicy struct {
+	items    map[entryRef]*list.Element
+	buckets  map[int]*list.List
+	minFreq  int
+	minDirty bool // minFreq 所在桶被清空后需要重新计算
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{items: make(map[entryRef]*list.Element), buckets: make(map[int]*list.List)}
+}
+
+func (p *lfuPolicy) bucket(freq int) *list.List {
+	b, ok := p.buckets[freq]
+	if !ok {
+		b = list.New()
+		p.buckets[freq] = b
+	}
+	return b
+}
+
+// unlink 从频率桶中移除元素，桶为空时删除
+func (p *lfuPolicy) unlink(e *list.Element) {
+	entry := e.Value.(*lfuEntry)
+	b := p.buckets[entry.freq]
+	b.Remove(e)
+	if b.Len() == 0 {
+		delete(p.buckets, entry.freq)
+		if entry.freq == p.minFreq {
+			p.minDirty = true
+		}
+	}
+}
+
+func (p *lfuPolicy) add(ref entryRef) {
+	p.items[ref] = p.bucket(1).PushFront(&lfuEntry{ref: ref, freq: 1})
+	p.minFreq = 1
+	p.minDirty = false
+}
+
+func (p *lfuPolicy) access(ref entryRef) {
+	e, ok := p.items[ref]
+	if !ok {
+		return
+	}
+	entry := e.Value.(*lfuEntry)
+	wasDirty := p.minDirty
+	p.unlink(e)
+	entry.freq++
+	p.items[ref] = p.bucket(entry.freq).PushFront(entry)
+	// 最低频率桶刚被清空时，新的最低频率就是该条目升级后的频率
+	if !wasDirty && p.minDirty {
+		p.minFreq = entry.freq
+		p.minDirty = false
+	}
+}
+
+func (p *lfuPolicy) remove(ref entryRef) {
+	if e, ok := p.items[ref]; ok {
+		p.unlink(e)
+		delete(p.items, ref)
+	}
+}
+
+func (p *lfuPolicy) peek(exclude entryRef) (entryRef, bool) {
+	if len(p.items) == 0 {
+		return entryRef{}, false
+	}
+	if p.minDirty {
+		p.minFreq = 0
+		for freq := range p.buckets {
+			if p.minFreq == 0 || freq < p.minFreq {
+				p.minFreq = freq
+			}
+		}
+		p.minDirty = false
+	}
+
+	if ref, ok := p.peekBucket(p.minFreq, exclude); ok {
+		return ref, true
+	}
+
+	// 最低频率桶只剩被排除的条目时，按频率从低到高依次尝试
+	freqs := make([]int, 0, len(p.buckets))
+	for freq := range p.buckets {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+	for _, freq := range freqs {
+		if ref, ok := p.peekBucket(freq, exclude); ok {
+			return ref, true
+		}
+	}
+	return entryRef{}, false
+}
+
+// peekBucket 返回指定频率桶中最久未访问的条目
+func (p *lfuPolicy) peekBucket(freq int, exclude entryRef) (entryRef, bool) {
+	b, ok := p.buckets[freq]
+	if !ok {
+		return entryRef{}, false
+	}
+	for e := b.Back(); e != nil; e = e.Prev() {
+		if entry := e.Value.(*lfuEntry); entry.ref != exclude {
+			return entry.ref, true
+		}
+	}
+	return entryRef{}, false
+}
+
+func (p *lfuPolicy) victim(exclude entryRef) (entryRef, bool) {
+	ref, ok := p.peek(exclude)
+	if ok {
+		p.remove(ref)
+	}
+	return ref, ok
+}
+
+func (p *lfuPolicy) len() int {
+	return len(p.items)
+}
+
+// ARC 条目所在的列表
+const (
+	arcT1 = iota // 最近访问一次
+	arcT2        // 访问多次
+	arcB1        // T1 淘汰后的幽灵记录
+	arcB2        // T2 淘汰后的幽灵记录
+)
+
+// arcEntry ARC 条目
+type arcEntry struct {
+	elem  *list.Element
+	where int
+}
+
+// arcPolicy 自适应替换缓存（Megiddo & Modha），根据幽灵命中在近期性与频率之间自动调整
+type arcPolicy struct {
+	capacity int // 目标容量，0 表示按当前驻留条目数自适应
+	p        int // T1 的目标大小
+	lists    [4]*list.List
+	items    map[entryRef]*arcEntry
+}
+
+func newARCPolicy(capacity int) *arcPolicy {
+	a := &arcPolicy{capacity: capacity, items: make(map[entryRef]*arcEntry)}
+	for i := range a.lists {
+		a.lists[i] = list.New()
+	}
+	return a
+}
+
+// target 返回当前容量目标，仅按字节限制时以驻留条目数近似
+func (a *arcPolicy) target() int {
+	if a.capacity > 0 {
+		return a.capacity
+	}
+	if n := a.len(); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// move 将条目移动到指定列表头部
+func (a *arcPolicy) move(ref entryRef, entry *arcEntry, where int) {
+	a.lists[entry.where].Remove(entry.elem)
+	entry.elem = a.lists[where].PushFront(ref)
+	entry.where = where
+}
+
+// dropGhost 删除幽灵列表尾部的记录
+func (a *arcPolicy) dropGhost(where int) {
+	if e := a.lists[where].Back(); e != nil {
+		a.lists[where].Remove(e)
+		delete(a.items, e.Value.(entryRef))
+	}
+}
+
+func (a *arcPolicy) add(ref entryRef) {
+	c := a.target()
+	b1, b2 := a.lists[arcB1].Len(), a.lists[arcB2].Len()
+
+	if entry, ok := a.items[ref]; ok {
+		switch entry.where {
+		case arcB1:
+			// 幽灵命中 B1：说明 T1 过小，增大 p
+			delta := 1
+			if b1 > 0 && b2/b1 > 1 {
+				delta = b2 / b1
+			}
+			if a.p += delta; a.p > c {
+				a.p = c
+			}
+			a.move(ref, entry, arcT2)
+		case arcB2:
+			// 幽灵命中 B2：说明 T2 过小，减小 p
+			delta := 1
+			if b2 > 0 && b1/b2 > 1 {
+				delta = b1 / b2
+			}
+			if a.p -= delta; a.p < 0 {
+				a.p = 0
+			}
+			a.move(ref, entry, arcT2)
+		default:
+			a.move(ref, entry, arcT2)
+		}
+		return
+	}
+
+	a.items[ref] = &arcEntry{elem: a.lists[arcT1].PushFront(ref), where: arcT1}
+
+	// 控制幽灵列表长度: |T1|+|B1| <= c, 总长度 <= 2c
+	for a.lists[arcT1].Len()+a.lists[arcB1].Len() > c && a.lists[arcB1].Len() > 0 {
+		a.dropGhost(arcB1)
+	}
+	for len(a.items) > 2*c && a.lists[arcB2].Len() > 0 {
+		a.dropGhost(arcB2)
+	}
+}
+
+func (a *arcPolicy) access(ref entryRef) {
+	if entry, ok := a.items[ref]; ok && (entry.where == arcT1 || entry.where == arcT2) {
+		a.move(ref, entry, arcT2)
+	}
+}
+
+// remove 删除驻留条目，幽灵记录保留以便后续自适应
+func (a *arcPolicy) remove(ref entryRef) {
+	if entry, ok := a.items[ref]; ok && (entry.where == arcT1 || entry.where == arcT2) {
+		a.lists[entry.where].Remove(entry.elem)
+		delete(a.items, ref)
+	}
+}
+
+func (a *arcPolicy) peek(exclude entryRef) (entryRef, bool) {
+	first, second := arcT2, arcT1
+	if t1 := a.lists[arcT1].Len(); t1 > 0 && (t1 > a.p || a.lists[arcT2].Len() == 0) {
+		first, second = arcT1, arcT2
+	}
+
+	// 首选列表只剩被排除的条目时从另一列表淘汰
+	for _, from := range [2]int{first, second} {
+		for e := a.lists[from].Back(); e != nil; e = e.Prev() {
+			if ref := e.Value.(entryRef); ref != exclude {
+				return ref, true
+			}
+		}
+	}
+	return entryRef{}, false
+}
+
+// victim 淘汰驻留条目并转入对应幽灵列表
+func (a *arcPolicy) victim(exclude entryRef) (entryRef, bool) {
+	ref, ok := a.peek(exclude)
+	if !ok {
+		return ref, false
+	}
+	entry := a.items[ref]
+	if entry.where == arcT1 {
+		a.move(ref, entry, arcB1)
+	} else {
+		a.move(ref, entry, arcB2)
+	}
+	return ref, true
+}
+
+func (a *arcPolicy) len() int {
+	return a.lists[arcT1].Len() + a.lists[arcT2].Len()
+}
@@ -0,0 +1,784 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/19 09:12:05
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/19 09:12:05
+ * Description: 基于文件的持久化缓存实现
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFileSync          = AOFSyncEverySec
+	fileCompactMinGarbage    = 1 << 20 // 失效记录超过该字节数且超过有效数据时自动压缩
+	fileCompactCheckInterval = time.Second
+)
+
+// fileIndex 内存索引项，记录键最新一条记录在数据文件中的位置
+type fileIndex struct {
+	offset    int64
+	size      int64
+	expiresAt int64 // 绝对过期时间(UnixNano)，0 表示永不过期
+}
+
+// expired 是否已过期
+func (i fileIndex) expired(now time.Time) bool {
+	return i.expiresAt > 0 && now.UnixNano() >= i.expiresAt
+}
+
+// FileCache 文件缓存实现
+// 所有写操作以记录形式追加到数据文件，内存中只保存键到记录位置的索引，读取时按索引从文件读取
+// 记录格式与内存缓存的追加日志相同，启动时顺序扫描重建索引，末尾不完整的记录被截断
+type FileCache struct {
+	mu         sync.RWMutex
+	path       string
+	file       *os.File
+	size       int64                // 数据文件长度
+	garbage    int64                // 已被覆盖、删除或过期的记录字节数
+	items      map[string]fileIndex // 普通键索引
+	hashes     map[string]fileIndex // 哈希表索引
+	policy     AOFSyncPolicy
+	dirty      bool
+	defaultExp time.Duration
+	encoder    *valueEncoder
+	hashCodec  HashCodec
+	events     eventHub
+	stats      statsRecorder
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewFileCache 创建文件缓存实例，数据文件不存在时创建
+func NewFileCache(config *CacheConfig) (*FileCache, error) {
+	if config.FilePath == "" {
+		return nil, fmt.Errorf("file cache requires file path")
+	}
+	encoder, err := newValueEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+	policy := AOFSyncPolicy(config.FileSync)
+	if policy == "" {
+		policy = defaultFileSync
+	}
+
+	f := &FileCache{
+		path:       config.FilePath,
+		items:      make(map[string]fileIndex),
+		hashes:     make(map[string]fileIndex),
+		policy:     policy,
+		defaultExp: config.DefaultExp,
+		encoder:    encoder,
+		hashCodec:  HashCodec{Strict: config.StrictHash},
+		stopChan:   make(chan struct{}),
+	}
+
+	if dir := filepath.Dir(f.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create file cache dir failed: %w", err)
+		}
+	}
+	if err := replayAppendLog(f.path, f.index); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	cleanup := config.CleanupInt
+	if cleanup <= 0 {
+		cleanup = defaultCleanupInterval
+	}
+	f.wg.Add(1)
+	go f.background(cleanup)
+	return f, nil
+}
+
+// open 以追加方式打开数据文件
+func (f *FileCache) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open cache file %s failed: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat cache file %s failed: %w", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// index 启动扫描时按记录更新索引
+func (f *FileCache) index(entry *snapshotEntry, offset, size int64) error {
+	idx := fileIndex{offset: offset, size: size, expiresAt: entry.ExpiresAt}
+	table := f.items
+	if entry.IsHash {
+		table = f.hashes
+	}
+	if old, ok := table[entry.Key]; ok {
+		f.garbage += old.size
+	}
+	if entry.Deleted || idx.expired(time.Now()) {
+		delete(table, entry.Key)
+		f.garbage += size
+		return nil
+	}
+	table[entry.Key] = idx
+	return nil
+}
+
+// background 定期清理过期索引、刷盘以及在失效记录过多时压缩数据文件
+func (f *FileCache) background(cleanupInterval time.Duration) {
+	defer f.wg.Done()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+	tick := time.NewTicker(fileCompactCheckInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-cleanup.C:
+			f.deleteExpired()
+		case <-tick.C:
+			f.mu.Lock()
+			if f.policy != AOFSyncNo && f.dirty {
+				if err := f.file.Sync(); err == nil {
+					f.dirty = false
+				}
+			}
+			needCompact := f.garbage > fileCompactMinGarbage && f.garbage > f.size-f.garbage
+			f.mu.Unlock()
+			if needCompact {
+				_ = f.Compact() // 失败时保留原数据文件，下个周期重试
+			}
+		case <-f.stopChan:
+			return
+		}
+	}
+}
+
+// deleteExpired 从索引中移除过期的键并触发过期事件，过期记录在压缩时清除
+func (f *FileCache) deleteExpired() {
+	var expired []EvictionEvent
+	listen := f.events.hasListeners()
+	now := time.Now()
+
+	f.mu.Lock()
+	for _, isHash := range []bool{false, true} {
+		table := f.table(isHash)
+		for key, idx := range table {
+			if !idx.expired(now) {
+				continue
+			}
+			ev := EvictionEvent{Key: key, IsHash: isHash, Reason: EvictReasonExpired}
+			if listen {
+				ev.Value, _ = f.readValueLocked(idx, isHash)
+			}
+			expired = append(expired, ev)
+			delete(table, key)
+			f.garbage += idx.size
+		}
+	}
+	f.mu.Unlock()
+
+	for _, ev := range expired {
+		f.events.emit(ev)
+	}
+}
+
+// table 返回普通键或哈希表的索引
+func (f *FileCache) table(isHash bool) map[string]fileIndex {
+	if isHash {
+		return f.hashes
+	}
+	return f.items
+}
+
+// expiresAt 将调用方传入的过期时间转换为绝对时间：-1 永不过期，0 使用默认过期时间
+func (f *FileCache) expiresAt(expiration time.Duration) int64 {
+	if expiration == 0 {
+		expiration = f.defaultExp
+	}
+	if expiration <= 0 {
+		return 0
+	}
+	return time.Now().Add(expiration).UnixNano()
+}
+
+// writeLocked 追加一条记录并更新索引，调用方需持有 f.mu 写锁
+// 写入失败时截断到写入前的长度，避免不完整的记录导致之后的记录在重启时被丢弃
+func (f *FileCache) writeLocked(entry *snapshotEntry) error {
+	record := frameAOFRecord(entry)
+	if _, err := f.file.Write(record); err != nil {
+		if truncErr := f.file.Truncate(f.size); truncErr != nil {
+			return fmt.Errorf("write cache file failed: %w", errors.Join(err, truncErr))
+		}
+		return fmt.Errorf("write cache file failed: %w", err)
+	}
+	if f.policy == AOFSyncAlways {
+		if err := f.file.Sync(); err != nil {
+			return fmt.Errorf("fsync cache file failed: %w", err)
+		}
+	} else {
+		f.dirty = true
+	}
+
+	table := f.table(entry.IsHash)
+	if old, ok := table[entry.Key]; ok {
+		f.garbage += old.size
+	}
+	size := int64(len(record))
+	if entry.Deleted {
+		delete(table, entry.Key)
+		f.garbage += size
+	} else {
+		table[entry.Key] = fileIndex{offset: f.size, size: size, expiresAt: entry.ExpiresAt}
+	}
+	f.size += size
+	return nil
+}
+
+// readLocked 读取索引指向的记录，调用方需持有 f.mu 读锁或写锁
+func (f *FileCache) readLocked(idx fileIndex) (*snapshotEntry, error) {
+	record := make([]byte, idx.size)
+	if _, err := f.file.ReadAt(record, idx.offset); err != nil {
+		return nil, fmt.Errorf("read cache file failed: %w", err)
+	}
+	return parseAOFRecord(record)
+}
+
+// readValueLocked 读取并解码普通键的值或哈希表，用于事件通知
+func (f *FileCache) readValueLocked(idx fileIndex, isHash bool) (interface{}, error) {
+	entry, err := f.readLocked(idx)
+	if err != nil {
+		return nil, err
+	}
+	if isHash {
+		return f.decodeHash(entry.Hash)
+	}
+	return f.encoder.decode(entry.Value)
+}
+
+// lookup 读取未过期的记录，不存在时返回 nil
+func (f *FileCache) lookup(key string, isHash bool) (*snapshotEntry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.lookupLocked(key, isHash)
+}
+
+func (f *FileCache) lookupLocked(key string, isHash bool) (*snapshotEntry, error) {
+	idx, ok := f.table(isHash)[key]
+	if !ok || idx.expired(time.Now()) {
+		return nil, nil
+	}
+	return f.readLocked(idx)
+}
+
+// Get 获取缓存值
+func (f *FileCache) Get(key string) (value interface{}, found bool, err error) {
+	defer f.stats.read(opGet, time.Now(), &found, &err)
+	return f.get(key)
+}
+
+// get 获取缓存值，不计入统计，供批量读取使用
+func (f *FileCache) get(key string) (interface{}, bool, error) {
+	entry, err := f.lookup(key, false)
+	if err != nil || entry == nil {
+		return nil, false, err
+	}
+	val, err := f.encoder.decode(entry.Value)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Set 设置缓存值
+func (f *FileCache) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	defer f.stats.done(opSet, time.Now(), &err)
+	data, err := f.encoder.encode(value)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeLocked(&snapshotEntry{Key: key, Value: data, ExpiresAt: f.expiresAt(expiration)})
+}
+
+// Delete 删除缓存值
+func (f *FileCache) Delete(key string) (err error) {
+	defer f.stats.done(opDelete, time.Now(), &err)
+	f.mu.Lock()
+	idx, ok := f.items[key]
+	if !ok {
+		f.mu.Unlock()
+		return nil
+	}
+	var value interface{}
+	if f.events.hasListeners() {
+		value, _ = f.readValueLocked(idx, false)
+	}
+	err = f.writeLocked(&snapshotEntry{Key: key, Deleted: true})
+	f.mu.Unlock()
+
+	if err == nil && !idx.expired(time.Now()) {
+		f.events.emit(EvictionEvent{Key: key, Value: value, Reason: EvictReasonDeleted})
+	}
+	return err
+}
+
+// encodeHash 编码哈希表字段，配置了密钥时与 Redis 一样逐字段加密
+func (f *FileCache) encodeHash(value map[string]interface{}) (map[string]string, error) {
+	marked, err := f.hashCodec.EncodeMap(value)
+	if err != nil {
+		return nil, err
+	}
+	if f.encoder.keyring != nil {
+		for field, str := range marked {
+			sealed, err := f.encoder.seal([]byte(str))
+			if err != nil {
+				return nil, fmt.Errorf("encrypt field %s failed: %w", field, err)
+			}
+			marked[field] = string(sealed)
+		}
+	}
+	return marked, nil
+}
+
+// openHashValue 解密哈希表字段值，配置了密钥时拒绝未加密的值
+func (f *FileCache) openHashValue(val string) (string, error) {
+	return f.encoder.openHashValue(val)
+}
+
+// decodeHash 解密并解码哈希表字段
+func (f *FileCache) decodeHash(stored map[string]string) (map[string]interface{}, error) {
+	marked := make(map[string]string, len(stored))
+	for field, val := range stored {
+		opened, err := f.openHashValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt field %s failed: %w", field, err)
+		}
+		marked[field] = opened
+	}
+	return f.hashCodec.DecodeMap(marked)
+}
+
+// SetHash 设置哈希表，整体替换已有的哈希表
+func (f *FileCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) (err error) {
+	defer f.stats.done(opSetHash, time.Now(), &err)
+	fields, err := f.encodeHash(value)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeLocked(&snapshotEntry{Key: key, Hash: fields, IsHash: true, ExpiresAt: f.expiresAt(expiration)})
+}
+
+// GetHash 获取整个哈希表
+func (f *FileCache) GetHash(key string) (hash map[string]interface{}, err error) {
+	defer f.stats.done(opGetHash, time.Now(), &err)
+	entry, err := f.lookup(key, true)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, missErrorf("hash key %s not found", key)
+	}
+	return f.decodeHash(entry.Hash)
+}
+
+// GetHashField 获取哈希表字段
+func (f *FileCache) GetHashField(key, field string) (value string, err error) {
+	defer f.stats.done(opGetHashField, time.Now(), &err)
+	entry, err := f.lookup(key, true)
+	if err != nil {
+		return "", err
+	}
+	if entry == nil {
+		return "", missErrorf("hash key %s not found", key)
+	}
+	val, ok := entry.Hash[field]
+	if !ok {
+		return "", missErrorf("field %s not found in hash %s", field, key)
+	}
+	return f.openHashValue(val)
+}
+
+// GetHashFieldValue 获取哈希表字段并按类型标记解码，结果与 GetHash 中该字段一致
+func (f *FileCache) GetHashFieldValue(key, field string) (interface{}, error) {
+	marked, err := f.GetHashField(key, field)
+	if err != nil {
+		return nil, err
+	}
+	return f.hashCodec.Decode(marked)
+}
+
+// GetHashInt 获取整数哈希字段，字段类型不符时返回 ErrTypeMismatch
+func (f *FileCache) GetHashInt(key, field string) (int64, error) {
+	return hashInt(f.GetHashFieldValue(key, field))
+}
+
+// GetHashFloat 获取浮点数哈希字段，整数字段同样可以读取
+func (f *FileCache) GetHashFloat(key, field string) (float64, error) {
+	return hashFloat(f.GetHashFieldValue(key, field))
+}
+
+// GetHashString 获取字符串哈希字段
+func (f *FileCache) GetHashString(key, field string) (string, error) {
+	return hashString(f.GetHashFieldValue(key, field))
+}
+
+// GetHashBool 获取布尔哈希字段
+func (f *FileCache) GetHashBool(key, field string) (bool, error) {
+	return hashBool(f.GetHashFieldValue(key, field))
+}
+
+// GetHashBytes 获取二进制哈希字段
+func (f *FileCache) GetHashBytes(key, field string) ([]byte, error) {
+	return hashBytes(f.GetHashFieldValue(key, field))
+}
+
+// UpdateHash 更新哈希表的部分字段，其余字段与过期时间保持不变，哈希表不存在时创建
+func (f *FileCache) UpdateHash(key string, value map[string]interface{}) (err error) {
+	defer f.stats.done(opUpdateHash, time.Now(), &err)
+	fields, err := f.encodeHash(value)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry, err := f.lookupLocked(key, true)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return f.writeLocked(&snapshotEntry{Key: key, Hash: fields, IsHash: true, ExpiresAt: f.expiresAt(0)})
+	}
+	for field, val := range fields {
+		entry.Hash[field] = val
+	}
+	return f.writeLocked(entry)
+}
+
+// SetHashStruct 将结构体的导出字段写入哈希表，字段名取自 `cache:"name,omitempty"` 标签
+func (f *FileCache) SetHashStruct(key string, v interface{}, expiration time.Duration) error {
+	values, err := structToHash(v)
+	if err != nil {
+		return err
+	}
+	return f.SetHash(key, values, expiration)
+}
+
+// GetHashStruct 读取哈希表并写入 dst 指向的结构体，哈希表中不存在的字段保持原值
+func (f *FileCache) GetHashStruct(key string, dst interface{}) error {
+	values, err := f.GetHash(key)
+	if err != nil {
+		return err
+	}
+	return hashToStruct(values, dst)
+}
+
+// UpdateHashStruct 只写入结构体中指定的字段（按哈希表字段名），未指定时写入全部字段，不影响其他字段与过期时间
+func (f *FileCache) UpdateHashStruct(key string, v interface{}, fields ...string) error {
+	values, err := structToHash(v, fields...)
+	if err != nil {
+		return err
+	}
+	return f.UpdateHash(key, values)
+}
+
+// DelHash 删除哈希表字段，删除最后一个字段时哈希表随之删除
+func (f *FileCache) DelHash(key, field string) (err error) {
+	defer f.stats.done(opDelHash, time.Now(), &err)
+	f.mu.Lock()
+	entry, err := f.lookupLocked(key, true)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	if entry == nil {
+		f.mu.Unlock()
+		return missErrorf("hash key %s not found", key)
+	}
+	if _, ok := entry.Hash[field]; !ok {
+		f.mu.Unlock()
+		return missErrorf("field %s not found in hash %s", field, key)
+	}
+
+	delete(entry.Hash, field)
+	removed := len(entry.Hash) == 0
+	if removed {
+		err = f.writeLocked(&snapshotEntry{Key: key, IsHash: true, Deleted: true})
+	} else {
+		err = f.writeLocked(entry)
+	}
+	f.mu.Unlock()
+
+	if err == nil && removed {
+		f.events.emit(EvictionEvent{Key: key, Value: map[string]interface{}{}, IsHash: true, Reason: EvictReasonDeleted})
+	}
+	return err
+}
+
+// ExistHash 检查哈希表字段是否存在
+func (f *FileCache) ExistHash(key, field string) (exists bool, err error) {
+	defer f.stats.read(opExistHash, time.Now(), &exists, &err)
+	entry, err := f.lookup(key, true)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	_, ok := entry.Hash[field]
+	return ok, nil
+}
+
+// ExpireHash 设置哈希表过期时间，expiration 不大于 0 时永不过期
+func (f *FileCache) ExpireHash(key string, expiration time.Duration) (err error) {
+	defer f.stats.done(opExpireHash, time.Now(), &err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, err := f.lookupLocked(key, true)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return missErrorf("hash key %s not found", key)
+	}
+	entry.ExpiresAt = 0
+	if expiration > 0 {
+		entry.ExpiresAt = time.Now().Add(expiration).UnixNano()
+	}
+	return f.writeLocked(entry)
+}
+
+// MSet 批量设置缓存值
+func (f *FileCache) MSet(values map[string]interface{}, expiration time.Duration) error {
+	result, err := f.MSetBatch(values, expiration)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// MGet 批量获取缓存值
+func (f *FileCache) MGet(keys []string) (map[string]interface{}, error) {
+	result, err := f.MGetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return result.Values(), nil
+}
+
+// MSetBatch 批量设置缓存值，逐键返回写入结果，各键使用相同的过期时间
+func (f *FileCache) MSetBatch(values map[string]interface{}, expiration time.Duration) (result BatchResult, err error) {
+	defer f.stats.batch(opMSet, time.Now(), &result, &err)
+	result = make(BatchResult, len(values))
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := f.encoder.encode(value)
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		encoded[key] = data
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expiresAt := f.expiresAt(expiration)
+	for key, data := range encoded {
+		if err := f.writeLocked(&snapshotEntry{Key: key, Value: data, ExpiresAt: expiresAt}); err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		result[key] = BatchItem{Status: BatchOK}
+	}
+	return result, nil
+}
+
+// MGetBatch 批量获取缓存值，逐键返回命中、未命中状态
+func (f *FileCache) MGetBatch(keys []string) (result BatchResult, err error) {
+	defer f.stats.batch(opMGet, time.Now(), &result, &err)
+	result = make(BatchResult, len(keys))
+	for _, key := range keys {
+		val, found, err := f.get(key)
+		switch {
+		case err != nil:
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		case !found:
+			result[key] = BatchItem{Status: BatchMiss}
+		default:
+			result[key] = BatchItem{Status: BatchOK, Value: val}
+		}
+	}
+	return result, nil
+}
+
+// GetInto 获取缓存值并直接解码到 dst 指向的变量
+func (f *FileCache) GetInto(key string, dst interface{}) (found bool, err error) {
+	defer f.stats.read(opGet, time.Now(), &found, &err)
+	return f.getInto(key, dst)
+}
+
+// getInto 获取缓存值并解码到 dst，不计入统计，供批量读取使用
+func (f *FileCache) getInto(key string, dst interface{}) (bool, error) {
+	entry, err := f.lookup(key, false)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return true, f.encoder.decodeInto(entry.Value, dst)
+}
+
+// MGetInto 批量获取缓存值，newDst 为每个键创建目标指针，命中时 BatchItem.Value 为该指针
+func (f *FileCache) MGetInto(keys []string, newDst func(key string) interface{}) (result BatchResult, err error) {
+	defer f.stats.batch(opMGet, time.Now(), &result, &err)
+	result = make(BatchResult, len(keys))
+	for _, key := range keys {
+		dst := newDst(key)
+		found, err := f.getInto(key, dst)
+		switch {
+		case err != nil:
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		case !found:
+			result[key] = BatchItem{Status: BatchMiss}
+		default:
+			result[key] = BatchItem{Status: BatchOK, Value: dst}
+		}
+	}
+	return result, nil
+}
+
+// Stats 返回统计信息，键数量包含已过期但尚未被清理的条目
+func (f *FileCache) Stats() Stats {
+	stats := f.stats.snapshot(CacheTypeFile, &f.events)
+	f.mu.RLock()
+	stats.Items = len(f.items)
+	stats.Hashes = len(f.hashes)
+	f.mu.RUnlock()
+	return stats
+}
+
+// OnEvict 注册删除事件监听器（Delete 删除键、DelHash 删空哈希表）
+func (f *FileCache) OnEvict(listener EvictionListener) {
+	f.events.onEvict(listener)
+}
+
+// OnExpire 注册过期事件监听器，在清理协程移除过期项时触发
+func (f *FileCache) OnExpire(listener EvictionListener) {
+	f.events.onExpire(listener)
+}
+
+// Compact 将有效记录复制到新的数据文件并原子替换，清除被覆盖、删除与过期的记录
+// 失效记录超过有效数据且超过 1MB 时自动执行，压缩期间阻塞读写
+func (f *FileCache) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dir, base := filepath.Split(f.path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create cache file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后为空操作
+
+	now := time.Now()
+	var offset int64
+	items := make(map[string]fileIndex, len(f.items))
+	hashes := make(map[string]fileIndex, len(f.hashes))
+	copyTable := func(src, dst map[string]fileIndex) error {
+		for key, idx := range src {
+			if idx.expired(now) {
+				continue
+			}
+			record := make([]byte, idx.size)
+			if _, err := f.file.ReadAt(record, idx.offset); err != nil {
+				return err
+			}
+			if _, err := tmp.Write(record); err != nil {
+				return err
+			}
+			dst[key] = fileIndex{offset: offset, size: idx.size, expiresAt: idx.expiresAt}
+			offset += idx.size
+		}
+		return nil
+	}
+
+	err = copyTable(f.items, items)
+	if err == nil {
+		err = copyTable(f.hashes, hashes)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("compact cache file %s failed: %w", f.path, err)
+	}
+
+	// 重命名前打开新文件，句柄随重命名指向新路径；重命名后再打开失败只能留下指向已删除文件的旧句柄，之后的写入会丢失
+	file, err := os.OpenFile(tmp.Name(), os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open compacted cache file %s failed: %w", f.path, err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		file.Close()
+		return fmt.Errorf("replace cache file %s failed: %w", f.path, err)
+	}
+	_ = syncDir(dir) // 持久化重命名，部分平台不支持同步目录
+
+	f.file.Close()
+	f.file, f.size = file, offset
+	f.items, f.hashes, f.garbage, f.dirty = items, hashes, 0, false
+	return nil
+}
+
+// Close 关闭缓存，停止后台协程，刷盘并关闭数据文件
+func (f *FileCache) Close() error {
+	select {
+	case <-f.stopChan:
+		return fmt.Errorf("file cache already closed")
+	default:
+		close(f.stopChan)
+	}
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.file.Sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir 同步目录，使其中的文件重命名在崩溃后仍然有效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
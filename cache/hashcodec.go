@@ -0,0 +1,314 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 20:46:53
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 20:46:53
+ * Description: 哈希表字段值类型标记编解码器
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HashCodec 哈希表字段值编解码器，内存缓存与 Redis 缓存共用
+// 字段值编码为 "类型:值" 形式的字符串，如 "int:42"、"string:张三"，读取时按类型标记还原
+type HashCodec struct {
+	// Strict 严格模式：缺少或未知的类型标记、无法解析的值（如 "int:abc"）返回错误；
+	// 宽松模式保持历史行为：无法解析的数值为 0，缺少或未知标记的值原样返回字符串
+	Strict bool
+}
+
+// HashDecodeError 严格模式下哈希表字段解码失败，Fields 记录每个失败字段的错误
+type HashDecodeError struct {
+	Fields map[string]error
+}
+
+func (e *HashDecodeError) Error() string {
+	if len(e.Fields) == 0 {
+		return "hash decode failed"
+	}
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("hash decode failed for %d field(s), first %s: %v", len(fields), fields[0], e.Fields[fields[0]])
+}
+
+// Encode 将字段值编码为带类型标记的字符串，不支持的类型回退为 JSON
+func (c HashCodec) Encode(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "bool:true", nil
+		}
+		return "bool:false", nil
+	case int, int32, int64:
+		return fmt.Sprintf("int:%d", v), nil
+	case int8:
+		return fmt.Sprintf("int8:%d", v), nil // 8/16 位整数使用各自的标记，读取时还原为原类型
+	case int16:
+		return fmt.Sprintf("int16:%d", v), nil
+	case uint, uint32, uint64:
+		return fmt.Sprintf("uint:%d", v), nil // 独立标记，超过 MaxInt64 的值不会溢出
+	case uint8:
+		return fmt.Sprintf("uint8:%d", v), nil
+	case uint16:
+		return fmt.Sprintf("uint16:%d", v), nil
+	case float32, float64:
+		return fmt.Sprintf("float:%v", v), nil
+	case time.Time:
+		return "time:" + v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return "duration:" + v.String(), nil
+	case string:
+		return "string:" + v, nil
+	case []byte:
+		return "bytes:" + hex.EncodeToString(v), nil // 二进制转十六进制
+	case map[string]interface{}:
+		return c.encodeNested(v)
+	default:
+		// 其他复杂类型（如结构体）回退到 JSON 序列化
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return "json:" + string(data), nil
+	}
+}
+
+// Decode 按类型标记还原字段值
+func (c HashCodec) Decode(marked string) (interface{}, error) {
+	marker, raw, ok := strings.Cut(marked, ":")
+	if !ok {
+		if c.Strict {
+			return nil, fmt.Errorf("missing type marker in %q", marked)
+		}
+		return marked, nil // 无类型标记则保持原样
+	}
+
+	switch marker {
+	case "bool":
+		if !c.Strict {
+			return raw == "true", nil
+		}
+		return strconv.ParseBool(raw)
+	case "int":
+		val, err := strconv.ParseInt(raw, 10, 64)
+		return c.result(val, err)
+	case "int8", "int16":
+		bits, _ := strconv.Atoi(marker[len("int"):])
+		val, err := strconv.ParseInt(raw, 10, bits)
+		return c.result(narrowInt(val, bits), err)
+	case "uint":
+		val, err := strconv.ParseUint(raw, 10, 64)
+		return c.result(val, err)
+	case "uint8", "uint16":
+		bits, _ := strconv.Atoi(marker[len("uint"):])
+		val, err := strconv.ParseUint(raw, 10, bits)
+		return c.result(narrowUint(val, bits), err)
+	case "float":
+		val, err := strconv.ParseFloat(raw, 64)
+		return c.result(val, err)
+	case "time":
+		val, err := time.Parse(time.RFC3339Nano, raw)
+		return c.result(val, err)
+	case "duration":
+		val, err := time.ParseDuration(raw)
+		return c.result(val, err)
+	case "map":
+		return c.decodeNested(raw)
+	case "string":
+		return raw, nil
+	case "bytes":
+		data, err := hex.DecodeString(raw)
+		return c.result(data, err)
+	case "json":
+		var data interface{}
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			if c.Strict {
+				return nil, err
+			}
+			return raw, nil // 解析失败保留原始 JSON 字符串
+		}
+		return data, nil
+	default:
+		if c.Strict {
+			return nil, fmt.Errorf("unknown type marker %q", marker)
+		}
+		return marked, nil // 未知类型标记保持原样
+	}
+}
+
+// encodeNested 嵌套哈希表的每个值各自带类型标记，整体以 JSON 对象存储，读取时逐层还原原始类型
+func (c HashCodec) encodeNested(values map[string]interface{}) (string, error) {
+	marked, err := c.EncodeMap(values)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(marked)
+	if err != nil {
+		return "", err
+	}
+	return "map:" + string(data), nil
+}
+
+// decodeNested 还原嵌套哈希表
+func (c HashCodec) decodeNested(raw string) (interface{}, error) {
+	var marked map[string]string
+	if err := json.Unmarshal([]byte(raw), &marked); err != nil {
+		if c.Strict {
+			return nil, err
+		}
+		return raw, nil // 解析失败保留原始字符串
+	}
+	values, err := c.DecodeMap(marked)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// narrowInt 将解析结果转换为对应位宽的有符号整数
+func narrowInt(val int64, bits int) interface{} {
+	switch bits {
+	case 8:
+		return int8(val)
+	default:
+		return int16(val)
+	}
+}
+
+// narrowUint 将解析结果转换为对应位宽的无符号整数
+func narrowUint(val uint64, bits int) interface{} {
+	switch bits {
+	case 8:
+		return uint8(val)
+	default:
+		return uint16(val)
+	}
+}
+
+// result 宽松模式忽略解析错误，返回解析出的部分结果
+func (c HashCodec) result(value interface{}, err error) (interface{}, error) {
+	if err != nil && c.Strict {
+		return nil, err
+	}
+	return value, nil
+}
+
+// EncodeMap 编码整个哈希表
+func (c HashCodec) EncodeMap(values map[string]interface{}) (map[string]string, error) {
+	marked := make(map[string]string, len(values))
+	for field, value := range values {
+		str, err := c.Encode(value)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported type for field %s: %w", field, err)
+		}
+		marked[field] = str
+	}
+	return marked, nil
+}
+
+// DecodeMap 解码整个哈希表
+// 严格模式下存在解码失败的字段时返回 *HashDecodeError，同时返回其余解码成功的字段
+func (c HashCodec) DecodeMap(marked map[string]string) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(marked))
+	var errs map[string]error
+	for field, str := range marked {
+		value, err := c.Decode(str)
+		if err != nil {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[field] = err
+			continue
+		}
+		result[field] = value
+	}
+
+	if errs != nil {
+		return result, &HashDecodeError{Fields: errs}
+	}
+	return result, nil
+}
+
+// hashInt 将解码后的字段值转换为 int64，供 GetHashInt 使用
+func hashInt(value interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+		return rv.Int(), nil
+	case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uintptr && rv.Uint() <= math.MaxInt64:
+		return int64(rv.Uint()), nil
+	default:
+		return 0, fmt.Errorf("%w: hash field value %T is not int64", ErrTypeMismatch, value)
+	}
+}
+
+// hashFloat 将解码后的字段值转换为 float64，整数字段同样可以读取
+func hashFloat(value interface{}, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		return rv.Float(), nil
+	case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+		return float64(rv.Int()), nil
+	case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	default:
+		return 0, fmt.Errorf("%w: hash field value %T is not float64", ErrTypeMismatch, value)
+	}
+}
+
+// hashString 将解码后的字段值转换为 string
+func hashString(value interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: hash field value %T is not string", ErrTypeMismatch, value)
+	}
+	return str, nil
+}
+
+// hashBool 将解码后的字段值转换为 bool
+func hashBool(value interface{}, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: hash field value %T is not bool", ErrTypeMismatch, value)
+	}
+	return b, nil
+}
+
+// hashBytes 将解码后的字段值转换为 []byte
+func hashBytes(value interface{}, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: hash field value %T is not []byte", ErrTypeMismatch, value)
+	}
+	return data, nil
+}
@@ -0,0 +1,277 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 21:35:40
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 21:35:40
+ * Description: 结构体与哈希表的映射
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashStructTag 结构体字段标签名，格式为 `cache:"name,omitempty"`，name 为 "-" 时忽略该字段
+const hashStructTag = "cache"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// structField 结构体字段与哈希表字段的对应关系
+type structField struct {
+	name      string // 哈希表字段名
+	index     []int  // 结构体字段索引路径，支持嵌入结构体
+	omitEmpty bool
+}
+
+// structFieldCache 缓存各结构体类型解析出的字段，reflect.Type -> []structField
+var structFieldCache sync.Map
+
+// structFields 解析结构体的导出字段，结果按类型缓存，调用方不能修改返回的切片
+func structFields(t reflect.Type) []structField {
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.([]structField)
+	}
+	cached, _ := structFieldCache.LoadOrStore(t, typeFields(t))
+	return cached.([]structField)
+}
+
+// typeFields 按嵌入深度逐层展开嵌入结构体（无标签时）的字段，浅层的同名字段优先，同一层先出现的优先
+// 与 encoding/json 一样跳过已展开过的类型，自引用的嵌入指针（如 type Node struct{ *Node }）不会无限展开
+func typeFields(t reflect.Type) []structField {
+	type embeddedStruct struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []structField
+	seen := make(map[string]bool)
+	visited := make(map[reflect.Type]bool)
+	next := []embeddedStruct{{typ: t}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for _, es := range current {
+			if visited[es.typ] {
+				continue
+			}
+			visited[es.typ] = true
+
+			for i := 0; i < es.typ.NumField(); i++ {
+				sf := es.typ.Field(i)
+				tag := sf.Tag.Get(hashStructTag)
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+
+				path := append(append([]int(nil), es.index...), i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+					next = append(next, embeddedStruct{typ: ft, index: path})
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				if name == "" {
+					name = sf.Name
+				}
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				fields = append(fields, structField{name: name, index: path, omitEmpty: opts == "omitempty"})
+			}
+		}
+	}
+	return fields
+}
+
+// structValue 取得结构体值，v 可以是结构体或指向结构体的非 nil 指针
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("hash struct must not be nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("hash struct must be a struct or pointer to struct, got %T", v)
+	}
+	return rv, nil
+}
+
+// structToHash 将结构体转换为 SetHash 使用的字段表
+// only 非空时只转换指定的字段（按哈希表字段名），此时忽略 omitempty 以便写入零值
+func structToHash(v interface{}, only ...string) (map[string]interface{}, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := structFields(rv.Type())
+	var wanted map[string]bool
+	if len(only) > 0 {
+		wanted = make(map[string]bool, len(only))
+		for _, name := range only {
+			wanted[name] = true
+		}
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if wanted != nil && !wanted[f.name] {
+			continue
+		}
+		fv, ok := fieldByIndex(rv, f.index, false)
+		if !ok {
+			continue // 嵌入的结构体指针为 nil
+		}
+		if wanted == nil && f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		values[f.name] = hashFieldValue(fv)
+		delete(wanted, f.name)
+	}
+
+	for name := range wanted {
+		return nil, fmt.Errorf("hash struct %s has no field %s", rv.Type(), name)
+	}
+	return values, nil
+}
+
+// hashToStruct 将 GetHash 读取的字段表写入 dst 指向的结构体，哈希表中不存在的字段保持原值
+func hashToStruct(values map[string]interface{}, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination must be a non-nil pointer to struct, got %T", dst)
+	}
+	rv = rv.Elem()
+
+	for _, f := range structFields(rv.Type()) {
+		value, ok := values[f.name]
+		if !ok {
+			continue
+		}
+		fv, ok := fieldByIndex(rv, f.index, true)
+		if !ok {
+			return fmt.Errorf("hash field %s: cannot set embedded pointer to unexported struct", f.name)
+		}
+		if err := assignHashField(fv, value); err != nil {
+			return fmt.Errorf("hash field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// fieldByIndex 按索引路径取得字段，alloc 为 true 时为 nil 的嵌入结构体指针分配内存
+// 嵌入结构体指针为 nil 且无法分配（未导出的嵌入指针）时返回 false
+func fieldByIndex(rv reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc || !rv.CanSet() {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return rv, true
+}
+
+// hashFieldValue 将自定义的基础类型（如 type Status string）还原为对应的内置类型，使其使用专门的类型标记而不是 JSON
+func hashFieldValue(fv reflect.Value) interface{} {
+	t := fv.Type()
+	if t == timeType || t == durationType {
+		return fv.Interface()
+	}
+
+	switch fv.Kind() {
+	case reflect.Bool:
+		return fv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return fv.Float()
+	case reflect.String:
+		return fv.String()
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return fv.Convert(bytesType).Interface()
+		}
+	}
+	return fv.Interface()
+}
+
+// assignHashField 将解码后的字段值赋给结构体字段
+// 数值类型之间(溢出或丢失小数时返回 ErrTypeMismatch)、同种类的自定义类型可直接转换，JSON 标记的复杂类型（结构体、切片等）经 JSON 还原
+func assignHashField(fv reflect.Value, value interface{}) error {
+	if value == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	if fv.Kind() == reflect.Ptr {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := assignHashField(ptr.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	src := reflect.ValueOf(value)
+	switch {
+	case src.Type().AssignableTo(fv.Type()):
+		fv.Set(src)
+		return nil
+	case isNumberKind(src.Kind()) && isNumberKind(fv.Kind()):
+		converted, err := convertNumber(src, fv.Type())
+		if err != nil {
+			return err
+		}
+		fv.Set(converted)
+		return nil
+	case src.Kind() == fv.Kind() && src.Type().ConvertibleTo(fv.Type()):
+		fv.Set(src.Convert(fv.Type()))
+		return nil
+	}
+
+	switch src.Kind() {
+	case reflect.Map, reflect.Slice:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, fv.Addr().Interface()); err != nil {
+			return fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: cannot assign %T to %s", ErrTypeMismatch, value, fv.Type())
+}
@@ -9,7 +9,9 @@
 package cache
 
 import (
+	"crypto/tls"
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -18,6 +20,12 @@ type CacheType string
 const (
 	CacheTypeMemory CacheType = "memory"
 	CacheTypeRedis  CacheType = "redis"
+	// CacheTypeMultiLevel 多级缓存: 本地内存(L1) + Redis(L2)
+	CacheTypeMultiLevel CacheType = "multilevel"
+	// CacheTypeFile 文件缓存: 追加写入的数据文件 + 内存索引，无需外部服务即可持久化
+	CacheTypeFile CacheType = "file"
+	// CacheTypeArena 字节数组缓存: 条目序列化到预分配的环形缓冲区，减少大量条目时的垃圾回收开销
+	CacheTypeArena CacheType = "arena"
 
 	defaultRedisURL        = "localhost:6379"
 	defaultRedisPassword   = ""
@@ -27,6 +35,19 @@ const (
 	defaultCleanupInterval = 10 * time.Minute
 	defaultPoolSize        = 100
 	defaultMinIdleConns    = 10
+	defaultShardCount      = 16
+
+	defaultL1Expiration        = time.Minute
+	defaultInvalidationChannel = "goscache:invalidate:"
+)
+
+// RedisMode Redis 部署模式
+type RedisMode string
+
+const (
+	RedisModeStandalone RedisMode = "standalone" // 单节点
+	RedisModeCluster    RedisMode = "cluster"    // Redis Cluster
+	RedisModeSentinel   RedisMode = "sentinel"   // Redis Sentinel 主从故障转移
 )
 
 type CacheConfig struct {
@@ -36,10 +57,63 @@ type CacheConfig struct {
 	DB            int           `json:"db"`              // Redis数据库索引
 	Prefix        string        `json:"prefix"`          // Redis键前缀
 	DefaultExp    time.Duration `json:"default_exp"`     // 默认过期时间
-	CleanupInt    time.Duration `json:"cleanup_int"`     // 清理间隔(仅内存缓存)
+	CleanupInt    time.Duration `json:"cleanup_int"`     // 清理间隔，0 表示使用默认值
 	PoolSize      int           `json:"pool_size"`       // Redis连接池大小
 	MinIdleConns  int           `json:"min_idle_conns"`  // Redis最小空闲连接数
 	HashKeyExpiry time.Duration `json:"hash_key_expiry"` // 哈希表过期时间
+	StrictHash    bool          `json:"strict_hash"`     // 哈希表字段严格解码，类型标记无法解析时返回 *HashDecodeError
+
+	Username     string        `json:"username"`      // Redis ACL 用户名(Redis 6+)
+	DialTimeout  time.Duration `json:"dial_timeout"`  // Redis建立连接超时，0 使用 go-redis 默认值(5s)
+	ReadTimeout  time.Duration `json:"read_timeout"`  // Redis读超时，0 使用 go-redis 默认值(3s)
+	WriteTimeout time.Duration `json:"write_timeout"` // Redis写超时，0 使用 ReadTimeout
+
+	TLS         bool        `json:"tls"`           // 是否启用 TLS
+	TLSCAFile   string      `json:"tls_ca_file"`   // 自定义 CA 证书文件(PEM)，为空时使用系统根证书
+	TLSCertFile string      `json:"tls_cert_file"` // 客户端证书文件(PEM)，双向认证时使用
+	TLSKeyFile  string      `json:"tls_key_file"`  // 客户端私钥文件(PEM)
+	TLSConfig   *tls.Config `json:"-"`             // 直接指定的 TLS 配置，优先于证书文件
+
+	MaxEntries     int    `json:"max_entries"`     // 最大条目数，普通键与哈希表合计(仅内存缓存，0 表示不限制)
+	MaxBytes       int64  `json:"max_bytes"`       // 最大估算字节数(仅内存缓存，0 表示不限制)
+	EvictionPolicy string `json:"eviction_policy"` // 超出容量时的淘汰策略: lru、lfu 或 arc(仅内存缓存)
+	Admission      string `json:"admission"`       // 容量已满时的准入策略: 空或 tinylfu(仅内存缓存，需设置容量上限)
+	ShardCount     int    `json:"shard_count"`     // 分片数(仅内存缓存与字节数组缓存)，容量上限按分片平均分配
+
+	Mode         string   `json:"mode"`          // Redis部署模式: standalone(默认)、cluster 或 sentinel
+	ClusterAddrs []string `json:"cluster_addrs"` // Redis集群节点地址，为空时使用 URL
+	HashTag      bool     `json:"hash_tag"`      // 是否将键前缀包裹为哈希标签 {prefix}，使所有键落在同一集群槽位
+
+	SentinelMasterName string   `json:"sentinel_master_name"` // Sentinel 监控的主节点名称
+	SentinelAddrs      []string `json:"sentinel_addrs"`       // Sentinel 节点地址，为空时使用 URL
+	SentinelPassword   string   `json:"sentinel_password"`    // Sentinel 节点密码，Password 用于主节点
+
+	Codec            string `json:"codec"`              // 值编解码器: json(默认)、gob 或 raw
+	ValueCodec       Codec  `json:"-"`                  // 自定义编解码器，优先于 Codec
+	SerializeOnStore bool   `json:"serialize_on_store"` // 内存缓存写入时按编解码器序列化，读取时反序列化
+
+	Compression       string     `json:"compression"`        // Redis值压缩算法: 空(不压缩)、gzip 或 flate
+	CompressThreshold int        `json:"compress_threshold"` // 编码后超过该字节数才压缩，0 使用默认值 1024
+	Compressor        Compressor `json:"-"`                  // 自定义压缩算法，优先于 Compression
+
+	EncryptionKeyID string            `json:"encryption_key_id"` // Redis值加密使用的当前密钥 ID
+	EncryptionKeys  map[string][]byte `json:"-"`                 // AES 密钥(16/24/32 字节)，按 ID 索引，保留旧密钥以解密轮换前的数据
+	AllowPlaintext  bool              `json:"allow_plaintext"`   // 配置了密钥时仍允许读取未加密的值，仅用于迁移启用加密前写入的数据
+
+	SnapshotPath     string        `json:"snapshot_path"`     // 内存缓存快照文件，创建时加载，关闭时写入
+	SnapshotInterval time.Duration `json:"snapshot_interval"` // 定期写入快照的间隔，0 表示只在关闭时写入
+
+	AOFPath        string `json:"aof_path"`         // 内存缓存追加日志文件，创建时重放，之后的写操作追加记录
+	AOFSync        string `json:"aof_sync"`         // 追加日志刷盘策略: always、everysec(默认) 或 no
+	AOFRewriteSize int64  `json:"aof_rewrite_size"` // 日志超过该字节数时压缩为快照，0 使用默认值 64MB
+
+	FilePath string `json:"file_path"` // 文件缓存的数据文件路径
+	FileSync string `json:"file_sync"` // 文件缓存刷盘策略: always、everysec(默认) 或 no
+
+	ArenaSize int64 `json:"arena_size"` // 字节数组缓存的总字节数，按分片平均分配，0 使用默认值 64MB
+
+	L1Expiration        time.Duration `json:"l1_exp"`               // 多级缓存 L1 过期时间，L2 使用写入时传入的过期时间
+	InvalidationChannel string        `json:"invalidation_channel"` // 多级缓存失效通知频道，默认 goscache:invalidate:+前缀
 }
 
 type CacheInterface interface {
@@ -53,6 +127,16 @@ type CacheInterface interface {
 	SetHash(key string, value map[string]interface{}, expiration time.Duration) error
 	GetHash(key string) (map[string]interface{}, error)
 	GetHashField(key, field string) (string, error)
+	GetHashFieldValue(key, field string) (interface{}, error)
+	GetHashInt(key, field string) (int64, error)
+	GetHashFloat(key, field string) (float64, error)
+	GetHashString(key, field string) (string, error)
+	GetHashBool(key, field string) (bool, error)
+	GetHashBytes(key, field string) ([]byte, error)
+	UpdateHash(key string, value map[string]interface{}) error
+	SetHashStruct(key string, v interface{}, expiration time.Duration) error
+	GetHashStruct(key string, dst interface{}) error
+	UpdateHashStruct(key string, v interface{}, fields ...string) error
 	DelHash(key, field string) error
 	ExistHash(key, field string) (bool, error)
 	ExpireHash(key string, expiration time.Duration) error
@@ -60,6 +144,99 @@ type CacheInterface interface {
 	// 批量操作
 	MSet(values map[string]interface{}, expiration time.Duration) error
 	MGet(keys []string) (map[string]interface{}, error)
+	MSetBatch(values map[string]interface{}, expiration time.Duration) (BatchResult, error)
+	MGetBatch(keys []string) (BatchResult, error)
+
+	// 解码到调用方类型
+	GetInto(key string, dst interface{}) (bool, error)
+	MGetInto(keys []string, newDst func(key string) interface{}) (BatchResult, error)
+
+	// 事件监听
+	OnEvict(listener EvictionListener)
+	OnExpire(listener EvictionListener)
+
+	// 统计
+	Stats() Stats
+}
+
+// BatchStatus 批量操作中单个键的执行状态
+type BatchStatus int
+
+const (
+	BatchOK    BatchStatus = iota // 成功（MGet 命中 / MSet 写入成功）
+	BatchMiss                     // 键不存在（仅 MGet）
+	BatchError                    // 解码或写入失败，详见 BatchItem.Err
+)
+
+// String 返回状态名称
+func (s BatchStatus) String() string {
+	switch s {
+	case BatchOK:
+		return "ok"
+	case BatchMiss:
+		return "miss"
+	case BatchError:
+		return "error"
+	default:
+		return fmt.Sprintf("BatchStatus(%d)", int(s))
+	}
+}
+
+// BatchItem 批量操作中单个键的结果
+type BatchItem struct {
+	Status BatchStatus
+	Value  interface{} // 仅 MGetBatch 且 Status 为 BatchOK 时有值
+	Err    error       // 仅 Status 为 BatchError 时有值
+}
+
+// BatchResult 批量操作结果，按键索引
+type BatchResult map[string]BatchItem
+
+// Values 返回所有成功获取的值
+func (r BatchResult) Values() map[string]interface{} {
+	values := make(map[string]interface{}, len(r))
+	for key, item := range r {
+		if item.Status == BatchOK {
+			values[key] = item.Value
+		}
+	}
+	return values
+}
+
+// Misses 返回所有未命中的键
+func (r BatchResult) Misses() []string {
+	var keys []string
+	for key, item := range r {
+		if item.Status == BatchMiss {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Errors 返回所有失败键及其错误
+func (r BatchResult) Errors() map[string]error {
+	errs := make(map[string]error)
+	for key, item := range r {
+		if item.Status == BatchError {
+			errs[key] = item.Err
+		}
+	}
+	return errs
+}
+
+// Err 返回按键名排序后第一个失败键的错误，全部成功时返回 nil
+func (r BatchResult) Err() error {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("batch operation failed for %d key(s), first %s: %w", len(keys), keys[0], errs[keys[0]])
 }
 
 // Option 配置选项函数类型
@@ -75,6 +252,41 @@ func WithRedisConfig(url, password, prefix string, db int) Option {
 	}
 }
 
+// WithUsername Redis ACL 用户名配置选项，密码通过 WithRedisConfig 设置
+func WithUsername(username string) Option {
+	return func(c *CacheConfig) {
+		c.Username = username
+	}
+}
+
+// WithTimeouts Redis连接超时配置选项，0 表示使用 go-redis 默认值
+func WithTimeouts(dial, read, write time.Duration) Option {
+	return func(c *CacheConfig) {
+		c.DialTimeout = dial
+		c.ReadTimeout = read
+		c.WriteTimeout = write
+	}
+}
+
+// WithTLS Redis TLS 配置选项，tlsConfig 为 nil 时使用系统根证书校验服务端
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(c *CacheConfig) {
+		c.TLS = true
+		c.TLSConfig = tlsConfig
+	}
+}
+
+// WithTLSFiles Redis TLS 证书文件配置选项
+// caFile 为自定义 CA 证书，certFile/keyFile 为双向认证的客户端证书，均可为空
+func WithTLSFiles(caFile, certFile, keyFile string) Option {
+	return func(c *CacheConfig) {
+		c.TLS = true
+		c.TLSCAFile = caFile
+		c.TLSCertFile = certFile
+		c.TLSKeyFile = keyFile
+	}
+}
+
 // WithExpiration 过期时间配置选项
 func WithExpiration(defaultExp, cleanupInt time.Duration) Option {
 	return func(c *CacheConfig) {
@@ -91,6 +303,118 @@ func WithPoolConfig(poolSize, minIdleConns int) Option {
 	}
 }
 
+// WithCluster Redis集群配置选项
+func WithCluster(addrs ...string) Option {
+	return func(c *CacheConfig) {
+		c.Mode = string(RedisModeCluster)
+		c.ClusterAddrs = addrs
+	}
+}
+
+// WithSentinel Redis Sentinel 配置选项，通过 Sentinel 发现主节点并在故障转移后自动切换
+func WithSentinel(masterName, sentinelPassword string, addrs ...string) Option {
+	return func(c *CacheConfig) {
+		c.Mode = string(RedisModeSentinel)
+		c.SentinelMasterName = masterName
+		c.SentinelPassword = sentinelPassword
+		c.SentinelAddrs = addrs
+	}
+}
+
+// WithHashTag 键前缀哈希标签配置选项，启用后同一缓存实例的键在集群中共置，可使用跨键命令
+func WithHashTag(enable bool) Option {
+	return func(c *CacheConfig) {
+		c.HashTag = enable
+	}
+}
+
+// WithCodec 值编解码器配置选项，默认使用 JSONCodec
+func WithCodec(codec Codec) Option {
+	return func(c *CacheConfig) {
+		c.ValueCodec = codec
+		if codec != nil {
+			c.Codec = codec.Name()
+		}
+	}
+}
+
+// WithSerializeOnStore 内存缓存序列化存储配置选项
+// 启用后写入时按编解码器编码为字节，读取得到与 Redis 相同类型的副本，调用方修改返回值不会影响缓存
+func WithSerializeOnStore(enable bool) Option {
+	return func(c *CacheConfig) {
+		c.SerializeOnStore = enable
+	}
+}
+
+// WithCompression Redis值压缩配置选项，编码后超过 threshold 字节的值压缩后存储，threshold 为 0 时使用默认值
+// 读取时根据头部自动识别并解压，未压缩的历史数据照常读取
+func WithCompression(compressor Compressor, threshold int) Option {
+	return func(c *CacheConfig) {
+		c.Compressor = compressor
+		c.CompressThreshold = threshold
+		if compressor != nil {
+			c.Compression = compressor.Name()
+		}
+	}
+}
+
+// WithEncryption Redis值 AES-GCM 加密配置选项，覆盖 Set/MSet 的值与 SetHash 的字段值
+// activeKeyID 为加密使用的密钥，keys 中的其他密钥仅用于解密，轮换时新增密钥并切换 activeKeyID 即可
+func WithEncryption(activeKeyID string, keys map[string][]byte) Option {
+	return func(c *CacheConfig) {
+		c.EncryptionKeyID = activeKeyID
+		c.EncryptionKeys = keys
+	}
+}
+
+// WithPlaintextMigration 配置了密钥时是否允许读取未加密的值，默认拒绝，避免绕过 AES-GCM 认证写入伪造的数据
+// 仅在迁移启用加密前写入的数据期间开启，旧数据全部重写后应关闭
+func WithPlaintextMigration(allow bool) Option {
+	return func(c *CacheConfig) {
+		c.AllowPlaintext = allow
+	}
+}
+
+// WithSnapshot 内存缓存快照配置选项，创建缓存时从 path 加载数据，每隔 interval 及关闭时写入快照
+// interval 为 0 时只在关闭时写入
+func WithSnapshot(path string, interval time.Duration) Option {
+	return func(c *CacheConfig) {
+		c.SnapshotPath = path
+		c.SnapshotInterval = interval
+	}
+}
+
+// WithAOF 内存缓存追加日志配置选项，创建缓存时加载压缩快照(path+".snap")并重放日志
+// policy 为空时使用 everysec
+func WithAOF(path string, policy AOFSyncPolicy) Option {
+	return func(c *CacheConfig) {
+		c.AOFPath = path
+		c.AOFSync = string(policy)
+	}
+}
+
+// WithAOFRewriteSize 追加日志自动压缩阈值配置选项
+func WithAOFRewriteSize(size int64) Option {
+	return func(c *CacheConfig) {
+		c.AOFRewriteSize = size
+	}
+}
+
+// WithFile 文件缓存配置选项，policy 为空时使用 everysec
+func WithFile(path string, policy AOFSyncPolicy) Option {
+	return func(c *CacheConfig) {
+		c.FilePath = path
+		c.FileSync = string(policy)
+	}
+}
+
+// WithArenaSize 字节数组缓存总容量配置选项，分片数通过 WithShards 设置
+func WithArenaSize(size int64) Option {
+	return func(c *CacheConfig) {
+		c.ArenaSize = size
+	}
+}
+
 // WithHashExpiry 哈希表过期时间配置选项
 func WithHashExpiry(expiry time.Duration) Option {
 	return func(c *CacheConfig) {
@@ -98,6 +422,50 @@ func WithHashExpiry(expiry time.Duration) Option {
 	}
 }
 
+// WithStrictHash 哈希表严格解码配置选项，默认宽松模式与历史行为一致（无法解析的值变为零值或原样返回）
+func WithStrictHash(strict bool) Option {
+	return func(c *CacheConfig) {
+		c.StrictHash = strict
+	}
+}
+
+// WithCapacity 容量限制配置选项(仅内存缓存)，maxEntries 与 maxBytes 为 0 表示不限制，上限按分片平均分配，各分片独立淘汰
+func WithCapacity(maxEntries int, maxBytes int64) Option {
+	return func(c *CacheConfig) {
+		c.MaxEntries = maxEntries
+		c.MaxBytes = maxBytes
+	}
+}
+
+// WithEvictionPolicy 淘汰策略配置选项(仅内存缓存)
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *CacheConfig) {
+		c.EvictionPolicy = string(policy)
+	}
+}
+
+// WithAdmission 准入策略配置选项(仅内存缓存，需同时通过 WithCapacity 设置容量上限)
+func WithAdmission(policy AdmissionPolicy) Option {
+	return func(c *CacheConfig) {
+		c.Admission = string(policy)
+	}
+}
+
+// WithShards 分片数配置选项(仅内存缓存与字节数组缓存)，需要全局严格的淘汰顺序时设置为 1
+func WithShards(count int) Option {
+	return func(c *CacheConfig) {
+		c.ShardCount = count
+	}
+}
+
+// WithMultiLevel 多级缓存配置选项，channel 为空时使用默认频道
+func WithMultiLevel(l1Expiration time.Duration, channel string) Option {
+	return func(c *CacheConfig) {
+		c.L1Expiration = l1Expiration
+		c.InvalidationChannel = channel
+	}
+}
+
 // InitCache 初始化缓存
 // 参数:
 // - 第一个参数: 缓存类型 (memory/redis)，可以是CacheType或字符串
@@ -109,7 +477,19 @@ func WithHashExpiry(expiry time.Duration) Option {
 //
 // NewCache 创建缓存实例
 func NewCache(cacheType CacheType, opts ...Option) (CacheInterface, error) {
-	config := &CacheConfig{
+	config := DefaultConfig(cacheType)
+
+	// 应用选项
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return newCache(config)
+}
+
+// DefaultConfig 返回填充默认值的配置，可在此基础上解码 JSON 或应用环境变量
+func DefaultConfig(cacheType CacheType) *CacheConfig {
+	return &CacheConfig{
 		Type:          string(cacheType),
 		URL:           defaultRedisURL,
 		Password:      defaultRedisPassword,
@@ -120,19 +500,33 @@ func NewCache(cacheType CacheType, opts ...Option) (CacheInterface, error) {
 		PoolSize:      defaultPoolSize,
 		MinIdleConns:  defaultMinIdleConns,
 		HashKeyExpiry: 0, // 默认不设置过期时间
+
+		EvictionPolicy: string(defaultEvictionPolicy),
+		ShardCount:     defaultShardCount,
+
+		Mode:         string(RedisModeStandalone),
+		L1Expiration: defaultL1Expiration,
 	}
+}
 
-	// 应用选项
-	for _, opt := range opts {
-		opt(config)
+// newCache 校验配置并按其中的类型创建缓存实例
+func newCache(config *CacheConfig) (CacheInterface, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cache config: %w", err)
 	}
 
-	switch cacheType {
+	switch CacheType(config.Type) {
 	case CacheTypeRedis:
 		return NewRedisCache(config)
 	case CacheTypeMemory:
 		return NewMemoryCache(config)
+	case CacheTypeMultiLevel:
+		return NewMultiLevelCache(config)
+	case CacheTypeFile:
+		return NewFileCache(config)
+	case CacheTypeArena:
+		return NewArenaCache(config)
 	default:
-		return nil, fmt.Errorf("unsupported cache type: %s", cacheType)
+		return nil, fmt.Errorf("unsupported cache type: %s", config.Type)
 	}
 }
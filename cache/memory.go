@@ -2,346 +2,367 @@
  * @Author: guxline zjguoxin@163.com
  * @Date: 2025/7/1 16:22:40
  * @LastEditors: guxline zjguoxin@163.com
- * @LastEditTime: 2025/7/1 16:22:40
+ * @LastEditTime: 2026/10/18 15:02:51
  * Description: 内存缓存实现
  * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
  */
 package cache
 
 import (
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
-
-	"github.com/patrickmn/go-cache"
 )
 
 // MemoryCache 内存缓存实现
+// 按键哈希分为多个分片，每个分片独立加锁，降低多核下的锁竞争
 type MemoryCache struct {
-	cache             *cache.Cache
-	hashMaps          map[string]map[string]interface{}
-	hashExpirations   map[string]time.Time
-	mu                sync.RWMutex
-	defaultExpiration time.Duration
-	cleanupInterval   time.Duration
-	stopChan          chan struct{}
+	shards   []*memoryShard
+	stopChan chan struct{}
+	events   eventHub
+	stats    statsRecorder
+	codec    Codec // 快照中普通键值的编解码器
+
+	snapshotPath string         // 快照文件路径，为空时不加载与定期写入
+	snapshotMu   sync.Mutex     // 串行化快照文件写入
+	aof          *appendLog     // 追加日志，未启用时为 nil
+	compactMu    sync.Mutex     // 串行化追加日志压缩
+	wg           sync.WaitGroup // 定期快照、日志刷盘等后台协程
 }
 
 // NewMemoryCache 创建新的内存缓存实例
+// 设置了容量上限(MaxEntries/MaxBytes)时按分片平均分配，各分片独立淘汰，合计不超过上限；配置了快照文件时加载其中的数据
 func NewMemoryCache(config *CacheConfig) (*MemoryCache, error) {
-	m := &MemoryCache{
-		cache:             cache.New(config.DefaultExp, config.CleanupInt),
-		hashMaps:          make(map[string]map[string]interface{}),
-		hashExpirations:   make(map[string]time.Time),
-		defaultExpiration: config.DefaultExp,
-		cleanupInterval:   config.CleanupInt,
-		stopChan:          make(chan struct{}),
+	shardCount := config.ShardCount
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	// 每个分片至少能容纳一个条目
+	if config.MaxEntries > 0 && shardCount > config.MaxEntries {
+		shardCount = config.MaxEntries
+	}
+	if config.MaxBytes > 0 && int64(shardCount) > config.MaxBytes {
+		shardCount = int(config.MaxBytes)
 	}
 
-	// 启动后台清理协程
-	go m.cleanupExpiredHashes()
+	codec, err := newCodec(config)
+	if err != nil {
+		return nil, err
+	}
 
+	m := &MemoryCache{
+		shards:       make([]*memoryShard, shardCount),
+		stopChan:     make(chan struct{}),
+		codec:        codec,
+		snapshotPath: config.SnapshotPath,
+	}
+	for i := range m.shards {
+		shard, err := newMemoryShard(shardConfig(config, i, shardCount), &m.events, m.stopChan)
+		if err != nil {
+			close(m.stopChan)
+			return nil, err
+		}
+		m.shards[i] = shard
+	}
+
+	if m.snapshotPath != "" {
+		if err := m.startSnapshots(config.SnapshotInterval); err != nil {
+			close(m.stopChan)
+			return nil, err
+		}
+	}
+	if config.AOFPath != "" {
+		if err := m.startAOF(config); err != nil {
+			close(m.stopChan)
+			m.wg.Wait()
+			return nil, err
+		}
+	}
 	return m, nil
 }
 
-// cleanupExpiredHashes 定期清理过期的哈希表
-func (m *MemoryCache) cleanupExpiredHashes() {
-	ticker := time.NewTicker(m.cleanupInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			m.mu.Lock()
-			now := time.Now()
-			for key, expiry := range m.hashExpirations {
-				if now.After(expiry) {
-					delete(m.hashMaps, key)
-					delete(m.hashExpirations, key)
-				}
-			}
-			m.mu.Unlock()
-		case <-m.stopChan:
-			return
+// shardConfig 第 i 个分片的配置，容量上限按分片数平均分配，除不尽的部分分给前面的分片
+func shardConfig(config *CacheConfig, i, shardCount int) *CacheConfig {
+	if config.MaxEntries <= 0 && config.MaxBytes <= 0 {
+		return config
+	}
+	c := *config
+	if c.MaxEntries > 0 {
+		c.MaxEntries = config.MaxEntries / shardCount
+		if i < config.MaxEntries%shardCount {
+			c.MaxEntries++
+		}
+	}
+	if c.MaxBytes > 0 {
+		c.MaxBytes = config.MaxBytes / int64(shardCount)
+		if int64(i) < config.MaxBytes%int64(shardCount) {
+			c.MaxBytes++
 		}
 	}
+	return &c
 }
 
-// Get 获取缓存值
-func (m *MemoryCache) Get(key string) (interface{}, bool, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	val, found := m.cache.Get(key)
-	return val, found, nil
+// ShardCount 实际使用的分片数，设置了容量上限时不超过最大条目数
+func (m *MemoryCache) ShardCount() int {
+	return len(m.shards)
 }
 
-// Set 设置缓存值
-func (m *MemoryCache) Set(key string, value interface{}, expiration time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// shard 按键的 FNV-1a 哈希选择分片
+func (m *MemoryCache) shard(key string) *memoryShard {
+	if len(m.shards) == 1 {
+		return m.shards[0]
+	}
 
-	var exp time.Duration
-	switch {
-	case expiration == -1:
-		exp = cache.NoExpiration
-	case expiration == 0:
-		exp = m.defaultExpiration
-	default:
-		exp = expiration
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
 	}
+	return m.shards[h%uint32(len(m.shards))]
+}
 
-	m.cache.Set(key, value, exp)
-	return nil
+// Get 获取缓存值
+func (m *MemoryCache) Get(key string) (value interface{}, found bool, err error) {
+	defer m.stats.read(opGet, time.Now(), &found, &err)
+	return m.shard(key).get(key)
 }
 
-// Delete 删除缓存值
-func (m *MemoryCache) Delete(key string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// Set 设置缓存值，超出容量限制时按淘汰策略移除其他条目
+func (m *MemoryCache) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	defer m.stats.done(opSet, time.Now(), &err)
+	return m.commit(m.shard(key).set(key, value, expiration))
+}
 
-	m.cache.Delete(key)
-	return nil
+// Delete 删除缓存值
+func (m *MemoryCache) Delete(key string) (err error) {
+	defer m.stats.done(opDelete, time.Now(), &err)
+	return m.commit(m.shard(key).delete(key))
 }
 
 // SetHash 设置哈希表
-func (m *MemoryCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// 初始化哈希表（原子性替换）
-	newHash := make(map[string]interface{}, len(value))
-
-	// 类型标记转换（与 Redis 方案一致）
-	for field, val := range value {
-		switch v := val.(type) {
-		case bool:
-			if v {
-				newHash[field] = "bool:true"
-			} else {
-				newHash[field] = "bool:false"
-			}
-		case int, int32, int64, uint, uint32, uint64:
-			newHash[field] = fmt.Sprintf("int:%v", v)
-		case float32, float64:
-			newHash[field] = fmt.Sprintf("float:%v", v)
-		case string:
-			newHash[field] = fmt.Sprintf("string:%s", v) // 明确标记字符串
-		case []byte:
-			newHash[field] = fmt.Sprintf("bytes:%x", v) // 二进制转十六进制
-		default:
-			// 复杂类型回退到 JSON
-			jsonData, err := json.Marshal(v)
-			if err != nil {
-				return fmt.Errorf("unsupported type for field %s: %w", field, err)
-			}
-			newHash[field] = fmt.Sprintf("json:%s", jsonData)
-		}
-	}
-
-	// 原子性更新哈希表
-	m.hashMaps[key] = newHash
-
-	// 设置过期时间
-	if expiration > 0 {
-		m.hashExpirations[key] = time.Now().Add(expiration)
-	} else if expiration == 0 && m.defaultExpiration > 0 {
-		m.hashExpirations[key] = time.Now().Add(m.defaultExpiration)
-	} else {
-		delete(m.hashExpirations, key) // 永久有效
-	}
-
-	return nil
+func (m *MemoryCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) (err error) {
+	defer m.stats.done(opSetHash, time.Now(), &err)
+	return m.commit(m.shard(key).setHash(key, value, expiration))
 }
 
 // GetHash 获取整个哈希表
-func (m *MemoryCache) GetHash(key string) (map[string]interface{}, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	// 检查过期
-	if expiry, exists := m.hashExpirations[key]; exists && time.Now().After(expiry) {
-		delete(m.hashMaps, key)
-		delete(m.hashExpirations, key)
-		return nil, fmt.Errorf("key expired")
-	}
+func (m *MemoryCache) GetHash(key string) (hash map[string]interface{}, err error) {
+	defer m.stats.done(opGetHash, time.Now(), &err)
+	return m.shard(key).getHash(key)
+}
 
-	// 获取原始数据
-	rawHash, exists := m.hashMaps[key]
-	if !exists {
-		return nil, fmt.Errorf("key not found")
-	}
+// GetHashField 获取哈希表字段
+func (m *MemoryCache) GetHashField(key, field string) (value string, err error) {
+	defer m.stats.done(opGetHashField, time.Now(), &err)
+	return m.shard(key).getHashField(key, field)
+}
 
-	// 类型转换
-	result := make(map[string]interface{}, len(rawHash))
-	for field, markedVal := range rawHash {
-		markedStr, ok := markedVal.(string)
-		if !ok {
-			result[field] = markedVal // 非字符串直接保留（如旧数据）
-			continue
-		}
+// GetHashFieldValue 获取哈希表字段并按类型标记解码，结果与 GetHash 中该字段一致
+func (m *MemoryCache) GetHashFieldValue(key, field string) (value interface{}, err error) {
+	defer m.stats.done(opGetHashField, time.Now(), &err)
+	return m.shard(key).getHashFieldValue(key, field)
+}
 
-		// 解析类型标记
-		parts := strings.SplitN(markedStr, ":", 2)
-		if len(parts) != 2 {
-			result[field] = markedStr // 无标记则保持字符串
-			continue
-		}
+// GetHashInt 获取整数哈希字段，字段类型不符时返回 ErrTypeMismatch
+func (m *MemoryCache) GetHashInt(key, field string) (int64, error) {
+	return hashInt(m.GetHashFieldValue(key, field))
+}
 
-		switch parts[0] {
-		case "bool":
-			result[field] = parts[1] == "true"
-		case "int":
-			val, _ := strconv.ParseInt(parts[1], 10, 64)
-			result[field] = val
-		case "float":
-			val, _ := strconv.ParseFloat(parts[1], 64)
-			result[field] = val
-		case "string":
-			result[field] = parts[1]
-		case "bytes":
-			data, _ := hex.DecodeString(parts[1])
-			result[field] = data
-		case "json":
-			var data interface{}
-			if err := json.Unmarshal([]byte(parts[1]), &data); err == nil {
-				result[field] = data
-			} else {
-				result[field] = parts[1] // 解析失败保留原始 JSON
-			}
-		default:
-			result[field] = markedStr // 未知标记保持原样
-		}
-	}
+// GetHashFloat 获取浮点数哈希字段，整数字段同样可以读取
+func (m *MemoryCache) GetHashFloat(key, field string) (float64, error) {
+	return hashFloat(m.GetHashFieldValue(key, field))
+}
 
-	return result, nil
+// GetHashString 获取字符串哈希字段
+func (m *MemoryCache) GetHashString(key, field string) (string, error) {
+	return hashString(m.GetHashFieldValue(key, field))
 }
 
-// GetHashField 获取哈希表字段
-func (m *MemoryCache) GetHashField(key, field string) (string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// GetHashBool 获取布尔哈希字段
+func (m *MemoryCache) GetHashBool(key, field string) (bool, error) {
+	return hashBool(m.GetHashFieldValue(key, field))
+}
 
-	if expiry, exists := m.hashExpirations[key]; exists && time.Now().After(expiry) {
-		return "", fmt.Errorf("hash key %s expired", key)
-	}
+// GetHashBytes 获取二进制哈希字段
+func (m *MemoryCache) GetHashBytes(key, field string) ([]byte, error) {
+	return hashBytes(m.GetHashFieldValue(key, field))
+}
 
-	hash, exists := m.hashMaps[key]
-	if !exists {
-		return "", fmt.Errorf("hash key %s not found", key)
-	}
+// UpdateHash 更新哈希表的部分字段，其余字段与过期时间保持不变，哈希表不存在时创建
+func (m *MemoryCache) UpdateHash(key string, value map[string]interface{}) (err error) {
+	defer m.stats.done(opUpdateHash, time.Now(), &err)
+	return m.commit(m.shard(key).updateHash(key, value))
+}
 
-	val, ok := hash[field]
-	if !ok {
-		return "", fmt.Errorf("field %s not found in hash %s", field, key)
+// SetHashStruct 将结构体的导出字段写入哈希表，字段名取自 `cache:"name,omitempty"` 标签
+func (m *MemoryCache) SetHashStruct(key string, v interface{}, expiration time.Duration) error {
+	values, err := structToHash(v)
+	if err != nil {
+		return err
 	}
-
-	return fmt.Sprintf("%v", val), nil
+	return m.SetHash(key, values, expiration)
 }
 
-// DelHash 删除哈希表字段
-func (m *MemoryCache) DelHash(key, field string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	hash, exists := m.hashMaps[key]
-	if !exists {
-		return fmt.Errorf("hash key %s not found", key)
+// GetHashStruct 读取哈希表并写入 dst 指向的结构体，哈希表中不存在的字段保持原值
+func (m *MemoryCache) GetHashStruct(key string, dst interface{}) error {
+	values, err := m.GetHash(key)
+	if err != nil {
+		return err
 	}
+	return hashToStruct(values, dst)
+}
 
-	if _, ok := hash[field]; !ok {
-		return fmt.Errorf("field %s not found in hash %s", field, key)
+// UpdateHashStruct 只写入结构体中指定的字段（按哈希表字段名），未指定时写入全部字段，不影响其他字段与过期时间
+func (m *MemoryCache) UpdateHashStruct(key string, v interface{}, fields ...string) error {
+	values, err := structToHash(v, fields...)
+	if err != nil {
+		return err
 	}
+	return m.UpdateHash(key, values)
+}
 
-	delete(hash, field)
-
-	if len(hash) == 0 {
-		delete(m.hashMaps, key)
-		delete(m.hashExpirations, key)
-	}
+// DelHash 删除哈希表字段，删除最后一个字段时哈希表随之删除
+func (m *MemoryCache) DelHash(key, field string) (err error) {
+	defer m.stats.done(opDelHash, time.Now(), &err)
+	return m.commit(m.shard(key).delHash(key, field))
+}
 
-	return nil
+// deleteHash 删除整个哈希表
+func (m *MemoryCache) deleteHash(key string) {
+	m.shard(key).deleteHash(key)
 }
 
 // ExistHash 检查哈希表字段是否存在
-func (m *MemoryCache) ExistHash(key, field string) (bool, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *MemoryCache) ExistHash(key, field string) (exists bool, err error) {
+	defer m.stats.read(opExistHash, time.Now(), &exists, &err)
+	return m.shard(key).existHash(key, field)
+}
 
-	if expiry, exists := m.hashExpirations[key]; exists && time.Now().After(expiry) {
-		return false, fmt.Errorf("hash key %s expired", key)
-	}
+// ExpireHash 设置哈希表过期时间
+func (m *MemoryCache) ExpireHash(key string, expiration time.Duration) (err error) {
+	defer m.stats.done(opExpireHash, time.Now(), &err)
+	return m.commit(m.shard(key).expireHash(key, expiration))
+}
 
-	hash, exists := m.hashMaps[key]
-	if !exists {
-		return false, nil
+// MSet 批量设置缓存值
+func (m *MemoryCache) MSet(values map[string]interface{}, expiration time.Duration) error {
+	result, err := m.MSetBatch(values, expiration)
+	if err != nil {
+		return err
 	}
-
-	_, ok := hash[field]
-	return ok, nil
+	return result.Err()
 }
 
-// ExpireHash 设置哈希表过期时间
-func (m *MemoryCache) ExpireHash(key string, expiration time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if _, exists := m.hashMaps[key]; !exists {
-		return fmt.Errorf("hash key %s not found", key)
+// MGet 批量获取缓存值
+func (m *MemoryCache) MGet(keys []string) (map[string]interface{}, error) {
+	result, err := m.MGetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
 	}
+	return result.Values(), nil
+}
 
-	if expiration > 0 {
-		m.hashExpirations[key] = time.Now().Add(expiration)
-	} else {
-		delete(m.hashExpirations, key)
+// MSetBatch 批量设置缓存值，逐键返回写入结果（超出容量上限的单个值会被拒绝）
+func (m *MemoryCache) MSetBatch(values map[string]interface{}, expiration time.Duration) (result BatchResult, err error) {
+	defer m.stats.batch(opMSet, time.Now(), &result, &err)
+	groups := make(map[*memoryShard]map[string]interface{})
+	for key, value := range values {
+		shard := m.shard(key)
+		if groups[shard] == nil {
+			groups[shard] = make(map[string]interface{})
+		}
+		groups[shard][key] = value
 	}
 
-	return nil
+	result = make(BatchResult, len(values))
+	for shard, group := range groups {
+		shard.msetBatch(group, expiration, result)
+	}
+	return result, m.commit(nil)
 }
 
-// MSet 批量设置缓存值
-func (m *MemoryCache) MSet(values map[string]interface{}, expiration time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	var exp time.Duration
-	switch {
-	case expiration == -1:
-		exp = cache.NoExpiration
-	case expiration == 0:
-		exp = m.defaultExpiration
-	default:
-		exp = expiration
+// MGetBatch 批量获取缓存值，逐键返回命中、未命中状态
+func (m *MemoryCache) MGetBatch(keys []string) (result BatchResult, err error) {
+	defer m.stats.batch(opMGet, time.Now(), &result, &err)
+	groups := make(map[*memoryShard][]string)
+	for _, key := range keys {
+		shard := m.shard(key)
+		groups[shard] = append(groups[shard], key)
 	}
 
-	for key, value := range values {
-		m.cache.Set(key, value, exp)
+	result = make(BatchResult, len(keys))
+	for shard, group := range groups {
+		shard.mgetBatch(group, result)
 	}
-
-	return nil
+	return result, nil
 }
 
-// MGet 批量获取缓存值
-func (m *MemoryCache) MGet(keys []string) (map[string]interface{}, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// GetInto 获取缓存值并赋给 dst 指向的变量，值类型可赋值或转换为目标类型，否则返回 ErrTypeMismatch
+func (m *MemoryCache) GetInto(key string, dst interface{}) (found bool, err error) {
+	defer m.stats.read(opGet, time.Now(), &found, &err)
+	return m.shard(key).getInto(key, dst)
+}
 
-	result := make(map[string]interface{}, len(keys))
+// MGetInto 批量获取缓存值，newDst 为每个键创建目标指针，命中时 BatchItem.Value 为该指针
+func (m *MemoryCache) MGetInto(keys []string, newDst func(key string) interface{}) (result BatchResult, err error) {
+	defer m.stats.batch(opMGet, time.Now(), &result, &err)
+	result = make(BatchResult, len(keys))
 	for _, key := range keys {
-		if val, found := m.cache.Get(key); found {
-			result[key] = val
+		dst := newDst(key)
+		found, err := m.shard(key).getInto(key, dst)
+		switch {
+		case err != nil:
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		case !found:
+			result[key] = BatchItem{Status: BatchMiss}
+		default:
+			result[key] = BatchItem{Status: BatchOK, Value: dst}
 		}
 	}
-
 	return result, nil
 }
 
-// Close 关闭缓存，释放资源
+// OnEvict 注册删除及淘汰事件监听器（Delete 删除键、DelHash 删空哈希表、超出容量被淘汰）
+func (m *MemoryCache) OnEvict(listener EvictionListener) {
+	m.events.onEvict(listener)
+}
+
+// OnExpire 注册过期事件监听器，在 go-cache 或哈希表清理协程移除过期项时触发
+func (m *MemoryCache) OnExpire(listener EvictionListener) {
+	m.events.onExpire(listener)
+}
+
+// Stats 返回统计信息，普通键数量包含已过期但尚未被清理的条目
+func (m *MemoryCache) Stats() Stats {
+	stats := m.stats.snapshot(CacheTypeMemory, &m.events)
+	for _, shard := range m.shards {
+		items, hashes := shard.counts()
+		stats.Items += items
+		stats.Hashes += hashes
+	}
+	return stats
+}
+
+// Close 关闭缓存，停止所有分片的清理协程，配置了快照文件时写入最后一次快照，启用追加日志时刷盘并关闭日志
 func (m *MemoryCache) Close() error {
-	close(m.stopChan)
-	return nil
+	select {
+	case <-m.stopChan:
+		return fmt.Errorf("memory cache already closed")
+	default:
+		close(m.stopChan)
+	}
+	m.wg.Wait()
+
+	var errs []error
+	if m.snapshotPath != "" {
+		errs = append(errs, m.saveSnapshotFile(m.snapshotPath))
+	}
+	if m.aof != nil {
+		errs = append(errs, m.aof.close())
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,672 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 15:02:51
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 15:02:51
+ * Description: 内存缓存分片
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// memoryShard 内存缓存分片，每个分片持有独立的 go-cache、哈希表、读写锁与容量限制
+type memoryShard struct {
+	cache             *cache.Cache
+	hashMaps          map[string]map[string]interface{}
+	hashExpirations   map[string]time.Time
+	mu                sync.RWMutex
+	defaultExpiration time.Duration
+	cleanupInterval   time.Duration
+	stopChan          <-chan struct{}
+	events            *eventHub
+	removingMu        sync.Mutex
+	removing          map[string]EvictReason // 正在主动移除的键，用于区分删除、淘汰与过期
+	limiter           *capacityLimiter       // 容量限制，未配置上限时为 nil
+	codec             Codec                  // 序列化存储模式的编解码器，未启用时为 nil
+	hashCodec         HashCodec
+	log               *appendLog // 追加日志，未启用时为 nil
+	logCodec          Codec      // 追加日志中普通键值的编解码器
+}
+
+// newMemoryShard 创建分片并启动该分片的哈希表清理协程
+func newMemoryShard(config *CacheConfig, events *eventHub, stopChan <-chan struct{}) (*memoryShard, error) {
+	limiter, err := newCapacityLimiter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var codec Codec
+	if config.SerializeOnStore {
+		if codec, err = newCodec(config); err != nil {
+			return nil, err
+		}
+	}
+
+	cleanup := config.CleanupInt
+	if cleanup <= 0 {
+		cleanup = defaultCleanupInterval
+	}
+
+	s := &memoryShard{
+		cache:             cache.New(config.DefaultExp, cleanup),
+		hashMaps:          make(map[string]map[string]interface{}),
+		hashExpirations:   make(map[string]time.Time),
+		defaultExpiration: config.DefaultExp,
+		cleanupInterval:   cleanup,
+		stopChan:          stopChan,
+		events:            events,
+		removing:          make(map[string]EvictReason),
+		limiter:           limiter,
+		codec:             codec,
+		hashCodec:         HashCodec{Strict: config.StrictHash},
+	}
+	s.cache.OnEvicted(s.onItemEvicted)
+
+	// 启动后台清理协程
+	go s.cleanupExpiredHashes()
+
+	return s, nil
+}
+
+// cleanupExpiredHashes 定期清理过期的哈希表
+func (s *memoryShard) cleanupExpiredHashes() {
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			var expired []EvictionEvent
+			s.mu.Lock()
+			now := time.Now()
+			for key, expiry := range s.hashExpirations {
+				if now.After(expiry) {
+					expired = append(expired, EvictionEvent{
+						Key:    key,
+						Value:  s.hashMaps[key],
+						IsHash: true,
+						Reason: EvictReasonExpired,
+					})
+					delete(s.hashMaps, key)
+					delete(s.hashExpirations, key)
+				}
+			}
+			s.mu.Unlock()
+
+			for _, ev := range expired {
+				s.forget(entryRef{key: ev.Key, hash: true})
+				s.events.emit(ev)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// onItemEvicted go-cache 移除回调，显式删除、容量淘汰与过期清理都会触发
+func (s *memoryShard) onItemEvicted(key string, value interface{}) {
+	reason := EvictReasonExpired
+	s.removingMu.Lock()
+	if r, ok := s.removing[key]; ok {
+		reason = r
+	}
+	s.removingMu.Unlock()
+
+	s.forget(entryRef{key: key})
+	if s.codec != nil && s.events.hasListeners() {
+		if decoded, err := s.decode(value); err == nil {
+			value = decoded
+		}
+	}
+	s.events.emit(EvictionEvent{Key: key, Value: value, Reason: reason})
+}
+
+// encode 序列化存储模式下将值编码为字节，未启用时原样返回
+func (s *memoryShard) encode(value interface{}) (interface{}, error) {
+	if s.codec == nil {
+		return value, nil
+	}
+	return marshalValue(s.codec, value)
+}
+
+// decode 序列化存储模式下将字节解码为值，未启用时原样返回
+func (s *memoryShard) decode(stored interface{}) (interface{}, error) {
+	if s.codec == nil {
+		return stored, nil
+	}
+	data, ok := stored.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected stored value type %T", stored)
+	}
+	return unmarshalValue(s.codec, data)
+}
+
+// logValue 启用追加日志且未开启序列化存储时，在加锁前编码需要记录的值
+func (s *memoryShard) logValue(value, stored interface{}) ([]byte, error) {
+	if s.log == nil {
+		return nil, nil
+	}
+	if data, ok := stored.([]byte); ok && s.codec != nil {
+		return data, nil
+	}
+	return marshalValue(s.logCodec, value)
+}
+
+// logItem 记录普通键写入，调用方需持有 s.mu 写锁
+func (s *memoryShard) logItem(key string, data []byte, exp time.Duration) {
+	if s.log == nil {
+		return
+	}
+	var expiresAt int64
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp).UnixNano()
+	}
+	s.log.append(&snapshotEntry{Key: key, Value: data, ExpiresAt: expiresAt})
+}
+
+// logHash 记录哈希表写入后的完整状态，哈希表已不存在时记录删除，调用方需持有 s.mu 写锁
+func (s *memoryShard) logHash(key string) {
+	if s.log == nil {
+		return
+	}
+	entry, err := s.hashEntry(key)
+	if err != nil {
+		return // 字段值均为带类型标记的字符串，不会出错
+	}
+	s.log.append(&entry)
+}
+
+// appendLog 记录一条快照条目，调用方需持有 s.mu 写锁
+func (s *memoryShard) appendLog(entry *snapshotEntry) {
+	if s.log != nil {
+		s.log.append(entry)
+	}
+}
+
+// removeItem 删除 go-cache 中的条目，并记录移除原因供回调使用
+// go-cache 自身并发安全，调用时不应持有 s.mu，以便回调中的监听器可以访问缓存
+func (s *memoryShard) removeItem(key string, reason EvictReason) {
+	s.removingMu.Lock()
+	s.removing[key] = reason
+	s.removingMu.Unlock()
+
+	s.cache.Delete(key)
+
+	s.removingMu.Lock()
+	delete(s.removing, key)
+	s.removingMu.Unlock()
+}
+
+// admit 在容量限制器中登记写入，返回需要淘汰的条目以及是否准入，未配置容量限制时总是准入
+func (s *memoryShard) admit(ref entryRef, size int64) ([]entryRef, bool, error) {
+	if s.limiter == nil {
+		return nil, true, nil
+	}
+	return s.limiter.admit(ref, size)
+}
+
+// rejected 新条目被准入策略拒绝，视为写入后立即被淘汰
+func (s *memoryShard) rejected(ref entryRef, value interface{}) {
+	s.events.emit(EvictionEvent{Key: ref.key, Value: value, IsHash: ref.hash, Reason: EvictReasonCapacity})
+}
+
+// touch 在容量限制器中记录一次读取
+func (s *memoryShard) touch(ref entryRef) {
+	if s.limiter != nil {
+		s.limiter.touch(ref)
+	}
+}
+
+// forget 从容量限制器中移除条目
+func (s *memoryShard) forget(ref entryRef) {
+	if s.limiter != nil {
+		s.limiter.forget(ref)
+	}
+}
+
+// evict 移除容量淘汰选出的条目，调用时不应持有 s.mu
+func (s *memoryShard) evict(victims []entryRef) {
+	for _, ref := range victims {
+		if !ref.hash {
+			s.removeItem(ref.key, EvictReasonCapacity)
+			continue
+		}
+
+		s.mu.Lock()
+		hash, exists := s.hashMaps[ref.key]
+		delete(s.hashMaps, ref.key)
+		delete(s.hashExpirations, ref.key)
+		s.mu.Unlock()
+
+		// 淘汰选出后到此处之间可能被重新写入，再次移除统计以保持一致
+		s.forget(ref)
+		if exists {
+			s.events.emit(EvictionEvent{Key: ref.key, Value: hash, IsHash: true, Reason: EvictReasonCapacity})
+		}
+	}
+}
+
+// itemExpiration 将调用方传入的过期时间转换为 go-cache 过期时间
+func (s *memoryShard) itemExpiration(expiration time.Duration) time.Duration {
+	switch {
+	case expiration == -1:
+		return cache.NoExpiration
+	case expiration == 0:
+		return s.defaultExpiration
+	default:
+		return expiration
+	}
+}
+
+// get 获取缓存值
+func (s *memoryShard) get(key string) (interface{}, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, found := s.cache.Get(key)
+	s.touch(entryRef{key: key})
+	if !found {
+		return nil, false, nil
+	}
+	val, err := s.decode(val)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// getInto 获取缓存值并赋给 dst，序列化存储模式下直接解码到 dst
+func (s *memoryShard) getInto(key string, dst interface{}) (bool, error) {
+	s.mu.RLock()
+	val, found := s.cache.Get(key)
+	s.touch(entryRef{key: key})
+	s.mu.RUnlock()
+
+	if !found {
+		return false, nil
+	}
+	if s.codec == nil {
+		return true, assignValue(dst, val)
+	}
+	data, ok := val.([]byte)
+	if !ok {
+		return true, fmt.Errorf("unexpected stored value type %T", val)
+	}
+	return true, unmarshalInto(s.codec, data, dst)
+}
+
+// set 设置缓存值，超出容量限制时按淘汰策略移除其他条目
+func (s *memoryShard) set(key string, value interface{}, expiration time.Duration) error {
+	stored, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	logData, err := s.logValue(value, stored)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	victims, admitted, err := s.admit(entryRef{key: key}, estimateSize(key, stored))
+	if err == nil && admitted {
+		exp := s.itemExpiration(expiration)
+		s.cache.Set(key, stored, exp)
+		s.logItem(key, logData, exp)
+	}
+	s.mu.Unlock()
+
+	if err == nil && !admitted {
+		s.rejected(entryRef{key: key}, value)
+	}
+	s.evict(victims)
+	return err
+}
+
+// delete 删除缓存值
+func (s *memoryShard) delete(key string) error {
+	s.removeItem(key, EvictReasonDeleted)
+
+	// 删除后到加锁前可能已被重新写入，此时该写入已有记录，不再记录删除
+	if s.log != nil {
+		s.mu.Lock()
+		if _, found := s.cache.Get(key); !found {
+			s.log.append(&snapshotEntry{Key: key, Deleted: true})
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// setHash 设置哈希表
+func (s *memoryShard) setHash(key string, value map[string]interface{}, expiration time.Duration) error {
+	s.mu.Lock()
+	victims, admitted, err := s.setHashLocked(key, value, expiration)
+	s.mu.Unlock()
+
+	if err == nil && !admitted {
+		s.rejected(entryRef{key: key, hash: true}, value)
+	}
+	s.evict(victims)
+	return err
+}
+
+// setHashLocked 设置哈希表，调用方需持有 s.mu 写锁
+func (s *memoryShard) setHashLocked(key string, value map[string]interface{}, expiration time.Duration) ([]entryRef, bool, error) {
+	// 初始化哈希表（原子性替换）
+	newHash := make(map[string]interface{}, len(value))
+
+	// 类型标记转换（与 Redis 方案一致）
+	marked, err := s.hashCodec.EncodeMap(value)
+	if err != nil {
+		return nil, false, err
+	}
+	for field, str := range marked {
+		newHash[field] = str
+	}
+
+	victims, admitted, err := s.admit(entryRef{key: key, hash: true}, estimateSize(key, newHash))
+	if err != nil || !admitted {
+		return nil, admitted, err
+	}
+
+	// 原子性更新哈希表
+	s.hashMaps[key] = newHash
+
+	// 设置过期时间
+	if expiration > 0 {
+		s.hashExpirations[key] = time.Now().Add(expiration)
+	} else if expiration == 0 && s.defaultExpiration > 0 {
+		s.hashExpirations[key] = time.Now().Add(s.defaultExpiration)
+	} else {
+		delete(s.hashExpirations, key) // 永久有效
+	}
+	s.logHash(key)
+
+	return victims, true, nil
+}
+
+// updateHash 合并写入哈希表字段，保留原有过期时间，哈希表不存在或已过期时按 setHash 创建
+func (s *memoryShard) updateHash(key string, value map[string]interface{}) error {
+	s.mu.Lock()
+	victims, admitted, err := s.updateHashLocked(key, value)
+	s.mu.Unlock()
+
+	if err == nil && !admitted {
+		s.rejected(entryRef{key: key, hash: true}, value)
+	}
+	s.evict(victims)
+	return err
+}
+
+// updateHashLocked 合并写入哈希表字段，调用方需持有 s.mu 写锁
+func (s *memoryShard) updateHashLocked(key string, value map[string]interface{}) ([]entryRef, bool, error) {
+	hash, exists := s.hashMaps[key]
+	if expiry, ok := s.hashExpirations[key]; !exists || (ok && time.Now().After(expiry)) {
+		return s.setHashLocked(key, value, 0)
+	}
+
+	marked, err := s.hashCodec.EncodeMap(value)
+	if err != nil {
+		return nil, false, err
+	}
+	newHash := make(map[string]interface{}, len(hash)+len(marked))
+	for field, val := range hash {
+		newHash[field] = val
+	}
+	for field, str := range marked {
+		newHash[field] = str
+	}
+
+	victims, admitted, err := s.admit(entryRef{key: key, hash: true}, estimateSize(key, newHash))
+	if err != nil || !admitted {
+		return nil, admitted, err
+	}
+	s.hashMaps[key] = newHash
+	s.logHash(key)
+	return victims, true, nil
+}
+
+// getHash 获取整个哈希表
+func (s *memoryShard) getHash(key string) (map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// 检查过期（读锁下不修改哈希表，由后台清理协程删除并触发过期事件）
+	if expiry, exists := s.hashExpirations[key]; exists && time.Now().After(expiry) {
+		return nil, missErrorf("key expired")
+	}
+
+	// 获取原始数据
+	rawHash, exists := s.hashMaps[key]
+	if !exists {
+		return nil, missErrorf("key not found")
+	}
+	s.touch(entryRef{key: key, hash: true})
+
+	// 类型转换
+	marked := make(map[string]string, len(rawHash))
+	legacy := make(map[string]interface{})
+	for field, markedVal := range rawHash {
+		if markedStr, ok := markedVal.(string); ok {
+			marked[field] = markedStr
+		} else {
+			legacy[field] = markedVal // 非字符串直接保留（如旧数据）
+		}
+	}
+
+	result, err := s.hashCodec.DecodeMap(marked)
+	for field, val := range legacy {
+		result[field] = val
+	}
+	return result, err
+}
+
+// getHashField 获取哈希表字段
+func (s *memoryShard) getHashField(key, field string) (string, error) {
+	val, err := s.lookupHashField(key, field)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", val), nil
+}
+
+// getHashFieldValue 获取哈希表字段并按类型标记解码
+func (s *memoryShard) getHashFieldValue(key, field string) (interface{}, error) {
+	val, err := s.lookupHashField(key, field)
+	if err != nil {
+		return nil, err
+	}
+	if marked, ok := val.(string); ok {
+		return s.hashCodec.Decode(marked)
+	}
+	return val, nil // 非字符串直接保留（如旧数据）
+}
+
+// lookupHashField 读取哈希表字段中存储的原始值
+func (s *memoryShard) lookupHashField(key, field string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if expiry, exists := s.hashExpirations[key]; exists && time.Now().After(expiry) {
+		return nil, missErrorf("hash key %s expired", key)
+	}
+
+	hash, exists := s.hashMaps[key]
+	if !exists {
+		return nil, missErrorf("hash key %s not found", key)
+	}
+	s.touch(entryRef{key: key, hash: true})
+
+	val, ok := hash[field]
+	if !ok {
+		return nil, missErrorf("field %s not found in hash %s", field, key)
+	}
+	return val, nil
+}
+
+// delHash 删除哈希表字段，删除最后一个字段时哈希表随之删除
+func (s *memoryShard) delHash(key, field string) error {
+	s.mu.Lock()
+
+	hash, exists := s.hashMaps[key]
+	if !exists {
+		s.mu.Unlock()
+		return missErrorf("hash key %s not found", key)
+	}
+
+	if _, ok := hash[field]; !ok {
+		s.mu.Unlock()
+		return missErrorf("field %s not found in hash %s", field, key)
+	}
+
+	delete(hash, field)
+
+	ref := entryRef{key: key, hash: true}
+	removed := len(hash) == 0
+	var victims []entryRef
+	if removed {
+		delete(s.hashMaps, key)
+		delete(s.hashExpirations, key)
+	} else {
+		// 字段减少后更新估算大小，不会产生新的淘汰对象
+		victims, _, _ = s.admit(ref, estimateSize(key, hash))
+	}
+	s.logHash(key)
+	s.mu.Unlock()
+
+	s.evict(victims)
+	if removed {
+		s.forget(ref)
+		s.events.emit(EvictionEvent{Key: key, Value: hash, IsHash: true, Reason: EvictReasonDeleted})
+	}
+	return nil
+}
+
+// deleteHash 删除整个哈希表
+func (s *memoryShard) deleteHash(key string) {
+	s.mu.Lock()
+	hash, exists := s.hashMaps[key]
+	delete(s.hashMaps, key)
+	delete(s.hashExpirations, key)
+	if exists {
+		s.logHash(key)
+	}
+	s.mu.Unlock()
+
+	if exists {
+		s.forget(entryRef{key: key, hash: true})
+		s.events.emit(EvictionEvent{Key: key, Value: hash, IsHash: true, Reason: EvictReasonDeleted})
+	}
+}
+
+// counts 返回普通键与哈希表的数量
+func (s *memoryShard) counts() (int, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.ItemCount(), len(s.hashMaps)
+}
+
+// existHash 检查哈希表字段是否存在
+func (s *memoryShard) existHash(key, field string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if expiry, exists := s.hashExpirations[key]; exists && time.Now().After(expiry) {
+		return false, missErrorf("hash key %s expired", key)
+	}
+
+	hash, exists := s.hashMaps[key]
+	if !exists {
+		return false, nil
+	}
+	s.touch(entryRef{key: key, hash: true})
+
+	_, ok := hash[field]
+	return ok, nil
+}
+
+// expireHash 设置哈希表过期时间
+func (s *memoryShard) expireHash(key string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.hashMaps[key]; !exists {
+		return missErrorf("hash key %s not found", key)
+	}
+
+	if expiration > 0 {
+		s.hashExpirations[key] = time.Now().Add(expiration)
+	} else {
+		delete(s.hashExpirations, key)
+	}
+	s.logHash(key)
+
+	return nil
+}
+
+// msetBatch 批量设置本分片的缓存值，结果写入 result（超出容量上限的单个值会被拒绝）
+func (s *memoryShard) msetBatch(values map[string]interface{}, expiration time.Duration, result BatchResult) {
+	exp := s.itemExpiration(expiration)
+	var victims []entryRef
+	var rejected []string
+
+	s.mu.Lock()
+	for key, value := range values {
+		stored, err := s.encode(value)
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		logData, err := s.logValue(value, stored)
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		evicted, admitted, err := s.admit(entryRef{key: key}, estimateSize(key, stored))
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		result[key] = BatchItem{Status: BatchOK}
+		if !admitted {
+			rejected = append(rejected, key)
+			continue
+		}
+		victims = append(victims, evicted...)
+		s.cache.Set(key, stored, exp)
+		s.logItem(key, logData, exp)
+	}
+	s.mu.Unlock()
+
+	for _, key := range rejected {
+		s.rejected(entryRef{key: key}, values[key])
+	}
+	s.evict(victims)
+}
+
+// mgetBatch 批量获取本分片的缓存值，结果写入 result
+func (s *memoryShard) mgetBatch(keys []string, result BatchResult) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range keys {
+		val, found := s.cache.Get(key)
+		s.touch(entryRef{key: key})
+		if !found {
+			result[key] = BatchItem{Status: BatchMiss}
+			continue
+		}
+		if val, err := s.decode(val); err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		} else {
+			result[key] = BatchItem{Status: BatchOK, Value: val}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/19 19:05:36
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/19 19:05:36
+ * Description: 以 Prometheus 文本格式导出缓存统计
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// metricsContentType Prometheus 文本格式 0.0.4
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsHandler 以 Prometheus 文本格式导出已注册缓存的统计信息，实现 http.Handler
+// 每次抓取时读取各缓存的 Stats()，不依赖 Prometheus 客户端库
+type MetricsHandler struct {
+	mu      sync.RWMutex
+	entries []metricsEntry
+}
+
+// metricsEntry 注册的缓存及其命名空间
+type metricsEntry struct {
+	namespace string
+	backend   CacheType
+	cache     CacheInterface
+}
+
+// metricsSample 一次抓取中单个缓存的统计快照
+type metricsSample struct {
+	labels string
+	stats  Stats
+}
+
+// NewMetricsHandler 创建指标导出处理器
+func NewMetricsHandler() *MetricsHandler {
+	return &MetricsHandler{}
+}
+
+// Register 注册缓存，namespace 作为 namespace 标签，为空时使用缓存的键前缀
+// 同一缓存类型下的命名空间不能重复，否则导出的时间序列会冲突
+func (h *MetricsHandler) Register(namespace string, c CacheInterface) error {
+	stats := c.Stats()
+	if namespace == "" {
+		namespace = stats.Prefix
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if e.namespace == namespace && e.backend == stats.Backend {
+			return fmt.Errorf("metrics for %s cache with namespace %q already registered", stats.Backend, namespace)
+		}
+	}
+	h.entries = append(h.entries, metricsEntry{namespace: namespace, backend: stats.Backend, cache: c})
+	return nil
+}
+
+// Unregister 取消注册缓存，缓存关闭前应先取消注册
+func (h *MetricsHandler) Unregister(c CacheInterface) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, e := range h.entries {
+		if e.cache == c {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// ServeHTTP 输出全部指标
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	_ = h.WriteMetrics(w)
+}
+
+// WriteMetrics 将全部指标按 Prometheus 文本格式写入 w，可用于推送网关或自定义的指标端点
+func (h *MetricsHandler) WriteMetrics(w io.Writer) error {
+	h.mu.RLock()
+	samples := make([]metricsSample, len(h.entries))
+	for i, e := range h.entries {
+		stats := e.cache.Stats()
+		samples[i] = metricsSample{
+			labels: fmt.Sprintf(`backend="%s",namespace="%s"`, escapeLabel(string(stats.Backend)), escapeLabel(e.namespace)),
+			stats:  stats,
+		}
+	}
+	h.mu.RUnlock()
+
+	var b strings.Builder
+	counter := func(name, help string, value func(Stats) uint64) {
+		writeFamily(&b, name, help, "counter")
+		for _, s := range samples {
+			writeSample(&b, name, s.labels, strconv.FormatUint(value(s.stats), 10))
+		}
+	}
+	gauge := func(name, help string, value func(Stats) int) {
+		writeFamily(&b, name, help, "gauge")
+		for _, s := range samples {
+			writeSample(&b, name, s.labels, strconv.Itoa(value(s.stats)))
+		}
+	}
+
+	counter("goscache_hits_total", "Number of cache reads that found the key.", func(s Stats) uint64 { return s.Hits })
+	counter("goscache_misses_total", "Number of cache reads that did not find the key.", func(s Stats) uint64 { return s.Misses })
+	counter("goscache_sets_total", "Number of successful writes.", func(s Stats) uint64 { return s.Sets })
+	counter("goscache_deletes_total", "Number of successful deletes.", func(s Stats) uint64 { return s.Deletes })
+	counter("goscache_evictions_total", "Number of entries evicted for capacity.", func(s Stats) uint64 { return s.Evictions })
+	counter("goscache_expirations_total", "Number of entries removed after expiring.", func(s Stats) uint64 { return s.Expirations })
+	counter("goscache_errors_total", "Number of failed operations.", func(s Stats) uint64 { return s.Errors })
+	gauge("goscache_items", "Number of plain keys currently stored.", func(s Stats) int { return s.Items })
+	gauge("goscache_hashes", "Number of hashes currently stored.", func(s Stats) int { return s.Hashes })
+
+	writeOperations(&b, samples)
+	writePool(&b, samples)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeOperations 输出各操作的调用次数、失败次数与耗时直方图
+func writeOperations(b *strings.Builder, samples []metricsSample) {
+	type opSample struct {
+		labels string
+		stats  OperationStats
+	}
+	var ops []opSample
+	for _, s := range samples {
+		names := make([]string, 0, len(s.stats.Operations))
+		for name := range s.stats.Operations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ops = append(ops, opSample{
+				labels: fmt.Sprintf(`%s,operation="%s"`, s.labels, escapeLabel(name)),
+				stats:  s.stats.Operations[name],
+			})
+		}
+	}
+
+	writeFamily(b, "goscache_operations_total", "Number of calls per operation.", "counter")
+	for _, op := range ops {
+		writeSample(b, "goscache_operations_total", op.labels, strconv.FormatUint(op.stats.Count, 10))
+	}
+	writeFamily(b, "goscache_operation_errors_total", "Number of failed calls per operation.", "counter")
+	for _, op := range ops {
+		writeSample(b, "goscache_operation_errors_total", op.labels, strconv.FormatUint(op.stats.Errors, 10))
+	}
+
+	const histogram = "goscache_operation_duration_seconds"
+	writeFamily(b, histogram, "Latency of cache operations.", "histogram")
+	for _, op := range ops {
+		for _, bucket := range op.stats.Buckets {
+			le := strconv.FormatFloat(bucket.UpperBound.Seconds(), 'g', -1, 64)
+			writeSample(b, histogram+"_bucket", op.labels+`,le="`+le+`"`, strconv.FormatUint(bucket.Count, 10))
+		}
+		writeSample(b, histogram+"_bucket", op.labels+`,le="+Inf"`, strconv.FormatUint(op.stats.Count, 10))
+		writeSample(b, histogram+"_sum", op.labels, strconv.FormatFloat(op.stats.Duration.Seconds(), 'g', -1, 64))
+		writeSample(b, histogram+"_count", op.labels, strconv.FormatUint(op.stats.Count, 10))
+	}
+}
+
+// writePool 输出 Redis 连接池统计，没有使用 Redis 的缓存不输出
+func writePool(b *strings.Builder, samples []metricsSample) {
+	hasPool := false
+	for _, s := range samples {
+		hasPool = hasPool || s.stats.Pool != nil
+	}
+	if !hasPool {
+		return
+	}
+
+	pool := func(name, help, typ string, value func(*redis.PoolStats) uint32) {
+		writeFamily(b, name, help, typ)
+		for _, s := range samples {
+			if s.stats.Pool != nil {
+				writeSample(b, name, s.labels, strconv.FormatUint(uint64(value(s.stats.Pool)), 10))
+			}
+		}
+	}
+	pool("goscache_pool_hits_total", "Number of times a free connection was found in the pool.", "counter",
+		func(p *redis.PoolStats) uint32 { return p.Hits })
+	pool("goscache_pool_misses_total", "Number of times a free connection was not found in the pool.", "counter",
+		func(p *redis.PoolStats) uint32 { return p.Misses })
+	pool("goscache_pool_timeouts_total", "Number of times waiting for a connection timed out.", "counter",
+		func(p *redis.PoolStats) uint32 { return p.Timeouts })
+	pool("goscache_pool_stale_connections_total", "Number of stale connections removed from the pool.", "counter",
+		func(p *redis.PoolStats) uint32 { return p.StaleConns })
+	pool("goscache_pool_connections", "Number of connections in the pool.", "gauge",
+		func(p *redis.PoolStats) uint32 { return p.TotalConns })
+	pool("goscache_pool_idle_connections", "Number of idle connections in the pool.", "gauge",
+		func(p *redis.PoolStats) uint32 { return p.IdleConns })
+}
+
+// writeFamily 输出指标的 HELP 与 TYPE 行
+func writeFamily(b *strings.Builder, name, help, typ string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeSample 输出一行样本
+func writeSample(b *strings.Builder, name, labels, value string) {
+	fmt.Fprintf(b, "%s{%s} %s\n", name, labels, value)
+}
+
+// labelEscaper 按文本格式转义标签值中的反斜杠、双引号与换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel 转义标签值
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,523 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 16:20:44
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 16:20:44
+ * Description: 多级缓存实现（L1 内存 + L2 Redis）
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationMessage 跨节点失效消息
+type invalidationMessage struct {
+	Node   string   `json:"node"`             // 发布节点ID，节点忽略自己发布的消息
+	Keys   []string `json:"keys,omitempty"`   // 需要失效的普通键
+	Hashes []string `json:"hashes,omitempty"` // 需要失效的哈希表键
+}
+
+// MultiLevelCache 多级缓存实现
+// 读取先查本地 L1 内存缓存，未命中再查 L2 Redis 并回填 L1；
+// 写入同时更新 L2 与本地 L1，并通过 Redis 发布/订阅通知其他节点删除各自 L1 中的旧数据
+type MultiLevelCache struct {
+	l1      *MemoryCache
+	l2      *RedisCache
+	l1Exp   time.Duration
+	channel string
+	nodeID  string
+	pubsub  *redis.PubSub
+	stats   statsRecorder
+
+	// generation 失效计数，收到失效消息或本节点写入 L2 后递增；
+	// 回填 L1 前后比较读取 L2 之前记录的值，避免把失效前读到的旧值写回 L1
+	generation atomic.Uint64
+}
+
+// NewMultiLevelCache 创建多级缓存实例
+// L1 使用 L1Expiration 作为过期时间(写入时的过期时间更短则取较短者)，L2 使用写入时传入的过期时间，0 与 -1 表示永不过期
+func NewMultiLevelCache(config *CacheConfig) (*MultiLevelCache, error) {
+	l2, err := NewRedisCache(config)
+	if err != nil {
+		return nil, err
+	}
+
+	l1Config := *config
+	l1Config.DefaultExp = config.L1Expiration
+	l1Config.SnapshotPath = "" // L1 仅是 L2 的副本，不做持久化
+	l1Config.AOFPath = ""
+	l1, err := NewMemoryCache(&l1Config)
+	if err != nil {
+		l2.Close()
+		return nil, err
+	}
+
+	nodeID := make([]byte, 8)
+	if _, err := rand.Read(nodeID); err != nil {
+		l1.Close()
+		l2.Close()
+		return nil, fmt.Errorf("failed to generate node id: %w", err)
+	}
+
+	channel := config.InvalidationChannel
+	if channel == "" {
+		channel = defaultInvalidationChannel + config.Prefix
+	}
+
+	mc := &MultiLevelCache{
+		l1:      l1,
+		l2:      l2,
+		l1Exp:   config.L1Expiration,
+		channel: channel,
+		nodeID:  hex.EncodeToString(nodeID),
+	}
+
+	// 等待订阅确认后再返回，避免丢失创建后立即发生的失效消息
+	mc.pubsub = l2.client.Subscribe(l2.ctx, channel)
+	if _, err := mc.pubsub.Receive(l2.ctx); err != nil {
+		mc.pubsub.Close()
+		l1.Close()
+		l2.Close()
+		return nil, fmt.Errorf("failed to subscribe invalidation channel: %w", err)
+	}
+	go mc.listenInvalidation(mc.pubsub.Channel())
+
+	return mc, nil
+}
+
+// listenInvalidation 处理其他节点发布的失效消息
+func (mc *MultiLevelCache) listenInvalidation(ch <-chan *redis.Message) {
+	for msg := range ch {
+		var inv invalidationMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil || inv.Node == mc.nodeID {
+			continue
+		}
+		mc.generation.Add(1)
+		for _, key := range inv.Keys {
+			mc.l1.Delete(key)
+		}
+		for _, key := range inv.Hashes {
+			mc.l1.deleteHash(key)
+		}
+	}
+}
+
+// publish 通知其他节点失效 L1 中的数据
+func (mc *MultiLevelCache) publish(keys, hashes []string) error {
+	if len(keys) == 0 && len(hashes) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(invalidationMessage{Node: mc.nodeID, Keys: keys, Hashes: hashes})
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+	if err := mc.l2.client.Publish(mc.l2.ctx, mc.channel, payload).Err(); err != nil {
+		return fmt.Errorf("redis publish failed: %w", err)
+	}
+	return nil
+}
+
+// invalidate 使进行中的 L1 回填作废，在 L2 写入成功后、修改本地 L1 之前调用
+func (mc *MultiLevelCache) invalidate() {
+	mc.generation.Add(1)
+}
+
+// fill 回填 L1 中的普通键，gen 为读取 L2 之前的失效计数
+// 读取期间发生过失效时不回填；回填后再检查一次，回填与失效交错时删除刚写入的值
+func (mc *MultiLevelCache) fill(values map[string]interface{}, gen uint64) {
+	if len(values) == 0 || mc.generation.Load() != gen {
+		return
+	}
+	_, _ = mc.l1.MSetBatch(values, mc.l1Exp)
+	if mc.generation.Load() != gen {
+		for key := range values {
+			mc.l1.Delete(key)
+		}
+	}
+}
+
+// fillHash 回填 L1 中的哈希表，规则同 fill
+func (mc *MultiLevelCache) fillHash(key string, hash map[string]interface{}, gen uint64) {
+	if mc.generation.Load() != gen {
+		return
+	}
+	_ = mc.l1.SetHash(key, hash, mc.l1Exp)
+	if mc.generation.Load() != gen {
+		mc.l1.deleteHash(key)
+	}
+}
+
+// l1Expiration 计算写入 L1 的过期时间，不超过 L2 的过期时间
+func (mc *MultiLevelCache) l1Expiration(expiration time.Duration) time.Duration {
+	if expiration > 0 && expiration < mc.l1Exp {
+		return expiration
+	}
+	return mc.l1Exp
+}
+
+// normalize 将值按 L2 的编解码器往返一次，保证各节点 L1 中的值类型与从 L2 读取时一致
+func (mc *MultiLevelCache) normalize(value interface{}) (interface{}, error) {
+	data, err := marshalValue(mc.l2.encoder.codec, value)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalValue(mc.l2.encoder.codec, data)
+}
+
+// Get 获取缓存值，L1 未命中时从 L2 读取并回填 L1
+func (mc *MultiLevelCache) Get(key string) (value interface{}, found bool, err error) {
+	defer mc.stats.read(opGet, time.Now(), &found, &err)
+	if val, found, _ := mc.l1.Get(key); found {
+		return val, true, nil
+	}
+
+	gen := mc.generation.Load()
+	val, found, err := mc.l2.Get(key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	mc.fill(map[string]interface{}{key: val}, gen)
+	return val, true, nil
+}
+
+// Set 设置缓存值，写入 L2 和本地 L1 并通知其他节点
+func (mc *MultiLevelCache) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	defer mc.stats.done(opSet, time.Now(), &err)
+	normalized, err := mc.normalize(value)
+	if err != nil {
+		return err
+	}
+	if err := mc.l2.Set(key, value, expiration); err != nil {
+		return err
+	}
+	mc.invalidate()
+	_ = mc.l1.Set(key, normalized, mc.l1Expiration(expiration))
+	return mc.publish([]string{key}, nil)
+}
+
+// Delete 删除缓存值
+func (mc *MultiLevelCache) Delete(key string) (err error) {
+	defer mc.stats.done(opDelete, time.Now(), &err)
+	if err := mc.l2.Delete(key); err != nil {
+		return err
+	}
+	mc.invalidate()
+	mc.l1.Delete(key)
+	return mc.publish([]string{key}, nil)
+}
+
+// SetHash 设置哈希表
+// Redis 的 HMSet 会与已有字段合并，因此只删除各节点 L1 中的旧哈希表，下次读取时再完整回填
+func (mc *MultiLevelCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) (err error) {
+	defer mc.stats.done(opSetHash, time.Now(), &err)
+	if err := mc.l2.SetHash(key, value, expiration); err != nil {
+		return err
+	}
+	mc.invalidate()
+	mc.l1.deleteHash(key)
+	return mc.publish(nil, []string{key})
+}
+
+// GetHash 获取整个哈希表，L1 未命中时从 L2 读取并回填 L1
+func (mc *MultiLevelCache) GetHash(key string) (hash map[string]interface{}, err error) {
+	defer func(start time.Time) { mc.stats.lookup(opGetHash, start, len(hash) > 0, err) }(time.Now()) // L2 中键不存在时返回空表
+	if hash, err := mc.l1.GetHash(key); err == nil {
+		return hash, nil
+	}
+
+	gen := mc.generation.Load()
+	hash, err = mc.l2.GetHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) > 0 {
+		mc.fillHash(key, hash, gen)
+	}
+	return hash, nil
+}
+
+// GetHashField 获取哈希表字段
+func (mc *MultiLevelCache) GetHashField(key, field string) (value string, err error) {
+	defer mc.stats.done(opGetHashField, time.Now(), &err)
+	if val, err := mc.l1.GetHashField(key, field); err == nil {
+		return val, nil
+	}
+	return mc.l2.GetHashField(key, field)
+}
+
+// GetHashFieldValue 获取哈希表字段并按类型标记解码，结果与 GetHash 中该字段一致
+func (mc *MultiLevelCache) GetHashFieldValue(key, field string) (value interface{}, err error) {
+	defer mc.stats.done(opGetHashField, time.Now(), &err)
+	if val, err := mc.l1.GetHashFieldValue(key, field); err == nil {
+		return val, nil
+	}
+	return mc.l2.GetHashFieldValue(key, field)
+}
+
+// GetHashInt 获取整数哈希字段，字段类型不符时返回 ErrTypeMismatch
+func (mc *MultiLevelCache) GetHashInt(key, field string) (int64, error) {
+	return hashInt(mc.GetHashFieldValue(key, field))
+}
+
+// GetHashFloat 获取浮点数哈希字段，整数字段同样可以读取
+func (mc *MultiLevelCache) GetHashFloat(key, field string) (float64, error) {
+	return hashFloat(mc.GetHashFieldValue(key, field))
+}
+
+// GetHashString 获取字符串哈希字段
+func (mc *MultiLevelCache) GetHashString(key, field string) (string, error) {
+	return hashString(mc.GetHashFieldValue(key, field))
+}
+
+// GetHashBool 获取布尔哈希字段
+func (mc *MultiLevelCache) GetHashBool(key, field string) (bool, error) {
+	return hashBool(mc.GetHashFieldValue(key, field))
+}
+
+// GetHashBytes 获取二进制哈希字段
+func (mc *MultiLevelCache) GetHashBytes(key, field string) ([]byte, error) {
+	return hashBytes(mc.GetHashFieldValue(key, field))
+}
+
+// UpdateHash 更新哈希表的部分字段，与 SetHash 一样只删除各节点 L1 中的旧哈希表
+func (mc *MultiLevelCache) UpdateHash(key string, value map[string]interface{}) (err error) {
+	defer mc.stats.done(opUpdateHash, time.Now(), &err)
+	if err := mc.l2.UpdateHash(key, value); err != nil {
+		return err
+	}
+	mc.invalidate()
+	mc.l1.deleteHash(key)
+	return mc.publish(nil, []string{key})
+}
+
+// SetHashStruct 将结构体的导出字段写入哈希表，字段名取自 `cache:"name,omitempty"` 标签
+func (mc *MultiLevelCache) SetHashStruct(key string, v interface{}, expiration time.Duration) error {
+	values, err := structToHash(v)
+	if err != nil {
+		return err
+	}
+	return mc.SetHash(key, values, expiration)
+}
+
+// GetHashStruct 读取哈希表并写入 dst 指向的结构体，哈希表中不存在的字段保持原值
+func (mc *MultiLevelCache) GetHashStruct(key string, dst interface{}) error {
+	values, err := mc.GetHash(key)
+	if err != nil {
+		return err
+	}
+	return hashToStruct(values, dst)
+}
+
+// UpdateHashStruct 只写入结构体中指定的字段（按哈希表字段名），未指定时写入全部字段，不影响其他字段与过期时间
+func (mc *MultiLevelCache) UpdateHashStruct(key string, v interface{}, fields ...string) error {
+	values, err := structToHash(v, fields...)
+	if err != nil {
+		return err
+	}
+	return mc.UpdateHash(key, values)
+}
+
+// DelHash 删除哈希表字段
+func (mc *MultiLevelCache) DelHash(key, field string) (err error) {
+	defer mc.stats.done(opDelHash, time.Now(), &err)
+	if err := mc.l2.DelHash(key, field); err != nil {
+		return err
+	}
+	mc.invalidate()
+	mc.l1.deleteHash(key)
+	return mc.publish(nil, []string{key})
+}
+
+// ExistHash 检查哈希表字段是否存在
+func (mc *MultiLevelCache) ExistHash(key, field string) (exists bool, err error) {
+	defer mc.stats.read(opExistHash, time.Now(), &exists, &err)
+	if exists, err := mc.l1.ExistHash(key, field); err == nil && exists {
+		return true, nil
+	}
+	return mc.l2.ExistHash(key, field)
+}
+
+// ExpireHash 设置哈希表过期时间
+func (mc *MultiLevelCache) ExpireHash(key string, expiration time.Duration) (err error) {
+	defer mc.stats.done(opExpireHash, time.Now(), &err)
+	if err := mc.l2.ExpireHash(key, expiration); err != nil {
+		return err
+	}
+	mc.invalidate()
+	mc.l1.deleteHash(key)
+	return mc.publish(nil, []string{key})
+}
+
+// MSet 批量设置缓存值
+func (mc *MultiLevelCache) MSet(values map[string]interface{}, expiration time.Duration) error {
+	result, err := mc.MSetBatch(values, expiration)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// MGet 批量获取缓存值
+func (mc *MultiLevelCache) MGet(keys []string) (map[string]interface{}, error) {
+	result, err := mc.MGetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return result.Values(), nil
+}
+
+// MSetBatch 批量设置缓存值，写入 L2 成功的键同步写入本地 L1 并通知其他节点
+func (mc *MultiLevelCache) MSetBatch(values map[string]interface{}, expiration time.Duration) (result BatchResult, err error) {
+	defer mc.stats.batch(opMSet, time.Now(), &result, &err)
+	result, err = mc.l2.MSetBatch(values, expiration)
+	if err != nil {
+		return nil, err
+	}
+	mc.invalidate()
+
+	l1Values := make(map[string]interface{}, len(values))
+	keys := make([]string, 0, len(values))
+	for key, item := range result {
+		if item.Status != BatchOK {
+			continue
+		}
+		keys = append(keys, key)
+		if normalized, err := mc.normalize(values[key]); err == nil {
+			l1Values[key] = normalized
+		}
+	}
+	_, _ = mc.l1.MSetBatch(l1Values, mc.l1Expiration(expiration))
+
+	return result, mc.publish(keys, nil)
+}
+
+// MGetBatch 批量获取缓存值，L1 未命中的键从 L2 读取并回填 L1
+func (mc *MultiLevelCache) MGetBatch(keys []string) (result BatchResult, err error) {
+	defer mc.stats.batch(opMGet, time.Now(), &result, &err)
+	result, _ = mc.l1.MGetBatch(keys)
+	misses := result.Misses()
+	if len(misses) == 0 {
+		return result, nil
+	}
+
+	gen := mc.generation.Load()
+	l2Result, err := mc.l2.MGetBatch(misses)
+	if err != nil {
+		return nil, err
+	}
+	mc.fill(l2Result.Values(), gen)
+	for key, item := range l2Result {
+		result[key] = item
+	}
+	return result, nil
+}
+
+// GetInto 获取缓存值并解码到 dst，L1 命中时按 L2 的编解码器转换，保证与从 L2 读取的结果一致
+func (mc *MultiLevelCache) GetInto(key string, dst interface{}) (found bool, err error) {
+	defer mc.stats.read(opGet, time.Now(), &found, &err)
+	if val, found, _ := mc.l1.Get(key); found {
+		return true, mc.convert(val, dst)
+	}
+
+	gen := mc.generation.Load()
+	found, err = mc.l2.GetInto(key, dst)
+	if err != nil || !found {
+		return found, err
+	}
+	if normalized, err := mc.normalize(reflect.ValueOf(dst).Elem().Interface()); err == nil {
+		mc.fill(map[string]interface{}{key: normalized}, gen)
+	}
+	return true, nil
+}
+
+// MGetInto 批量获取缓存值，L1 未命中的键从 L2 读取并回填 L1
+func (mc *MultiLevelCache) MGetInto(keys []string, newDst func(key string) interface{}) (result BatchResult, err error) {
+	defer mc.stats.batch(opMGet, time.Now(), &result, &err)
+	result, _ = mc.l1.MGetBatch(keys)
+	var misses []string
+	for _, key := range keys {
+		item := result[key]
+		if item.Status != BatchOK {
+			misses = append(misses, key)
+			continue
+		}
+		dst := newDst(key)
+		if err := mc.convert(item.Value, dst); err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+		} else {
+			result[key] = BatchItem{Status: BatchOK, Value: dst}
+		}
+	}
+	if len(misses) == 0 {
+		return result, nil
+	}
+
+	gen := mc.generation.Load()
+	l2Result, err := mc.l2.MGetInto(misses, newDst)
+	if err != nil {
+		return nil, err
+	}
+	fill := make(map[string]interface{}, len(l2Result))
+	for key, item := range l2Result {
+		result[key] = item
+		if item.Status != BatchOK {
+			continue
+		}
+		if normalized, err := mc.normalize(reflect.ValueOf(item.Value).Elem().Interface()); err == nil {
+			fill[key] = normalized
+		}
+	}
+	mc.fill(fill, gen)
+	return result, nil
+}
+
+// convert 将 L1 中的值按 L2 的编解码器编码后解码到 dst
+func (mc *MultiLevelCache) convert(value, dst interface{}) error {
+	data, err := marshalValue(mc.l2.encoder.codec, value)
+	if err != nil {
+		return err
+	}
+	return unmarshalInto(mc.l2.encoder.codec, data, dst)
+}
+
+// Stats 返回统计信息，命中与未命中按多级缓存整体计算，键数量取自 L1，连接池、淘汰与过期次数取自 L2
+func (mc *MultiLevelCache) Stats() Stats {
+	stats := mc.stats.snapshot(CacheTypeMultiLevel, &mc.l2.events)
+	l1 := mc.l1.Stats()
+	stats.Items = l1.Items
+	stats.Hashes = l1.Hashes
+	stats.Prefix = mc.l2.keyPrefix
+	stats.Pool = mc.l2.client.PoolStats()
+	return stats
+}
+
+// OnEvict 注册删除及淘汰事件监听器，事件来自 L2 的键空间通知
+func (mc *MultiLevelCache) OnEvict(listener EvictionListener) {
+	mc.l2.OnEvict(listener)
+}
+
+// OnExpire 注册过期事件监听器，事件来自 L2 的键空间通知
+func (mc *MultiLevelCache) OnExpire(listener EvictionListener) {
+	mc.l2.OnExpire(listener)
+}
+
+// Close 关闭多级缓存
+func (mc *MultiLevelCache) Close() error {
+	mc.pubsub.Close()
+	mc.l1.Close()
+	return mc.l2.Close()
+}
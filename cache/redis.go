@@ -10,11 +10,12 @@ package cache
 
 import (
 	"context"
-	"encoding/hex"
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"strconv"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -22,40 +23,200 @@ import (
 
 // RedisCache Redis缓存实现
 type RedisCache struct {
-	client    *redis.Client
+	client    redis.UniversalClient
 	ctx       context.Context
 	keyPrefix string
+	db        int
+	cluster   bool // 集群模式
+	coLocated bool // 集群模式下所有键通过哈希标签位于同一槽位
+	encoder   *valueEncoder
+	hashCodec HashCodec
+
+	events     eventHub
+	stats      statsRecorder
+	notifyOnce sync.Once
+	notifyMu   sync.Mutex
+	pubsubs    []*redis.PubSub
 }
 
 // NewRedisCache 创建Redis缓存实例
 func NewRedisCache(config *CacheConfig) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:         config.URL,
-		Password:     config.Password,
-		DB:           config.DB,
-		PoolSize:     config.PoolSize,
-		MinIdleConns: config.MinIdleConns,
-	})
+	encoder, err := newValueEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx := context.Background()
 	if _, err := client.Ping(ctx).Result(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	// 启用哈希标签时前缀包裹在 {} 中，同一实例的所有键落在同一个集群槽位
+	keyPrefix := config.Prefix
+	if config.HashTag && keyPrefix != "" {
+		keyPrefix = HashTagKey(keyPrefix, "")
+	}
+
 	return &RedisCache{
 		client:    client,
 		ctx:       ctx,
-		keyPrefix: config.Prefix,
+		keyPrefix: keyPrefix,
+		db:        config.DB,
+		cluster:   RedisMode(config.Mode) == RedisModeCluster,
+		coLocated: config.HashTag && config.Prefix != "",
+		encoder:   encoder,
+		hashCodec: HashCodec{Strict: config.StrictHash},
 	}, nil
 }
 
+// newRedisClient 按部署模式创建 Redis 客户端
+func newRedisClient(config *CacheConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch RedisMode(config.Mode) {
+	case RedisModeStandalone, "":
+		return redis.NewClient(&redis.Options{
+			Addr:         config.URL,
+			Username:     config.Username,
+			Password:     config.Password,
+			DB:           config.DB,
+			PoolSize:     config.PoolSize,
+			MinIdleConns: config.MinIdleConns,
+			DialTimeout:  config.DialTimeout,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		}), nil
+	case RedisModeCluster:
+		if config.DB != 0 {
+			return nil, fmt.Errorf("redis cluster does not support db %d", config.DB)
+		}
+		addrs := config.ClusterAddrs
+		if len(addrs) == 0 {
+			addrs = []string{config.URL}
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addrs,
+			Username:     config.Username,
+			Password:     config.Password,
+			PoolSize:     config.PoolSize,
+			MinIdleConns: config.MinIdleConns,
+			DialTimeout:  config.DialTimeout,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		}), nil
+	case RedisModeSentinel:
+		if config.SentinelMasterName == "" {
+			return nil, fmt.Errorf("redis sentinel requires master name")
+		}
+		addrs := config.SentinelAddrs
+		if len(addrs) == 0 {
+			addrs = []string{config.URL}
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       config.SentinelMasterName,
+			SentinelAddrs:    addrs,
+			SentinelPassword: config.SentinelPassword,
+			Username:         config.Username,
+			Password:         config.Password,
+			DB:               config.DB,
+			PoolSize:         config.PoolSize,
+			MinIdleConns:     config.MinIdleConns,
+			DialTimeout:      config.DialTimeout,
+			ReadTimeout:      config.ReadTimeout,
+			WriteTimeout:     config.WriteTimeout,
+			TLSConfig:        tlsConfig,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported redis mode: %s", config.Mode)
+	}
+}
+
+// newTLSConfig 根据配置构造 TLS 配置，未启用 TLS 时返回 nil
+func newTLSConfig(config *CacheConfig) (*tls.Config, error) {
+	if !config.TLS {
+		return nil, nil
+	}
+	if config.TLSConfig != nil {
+		return config.TLSConfig.Clone(), nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSCAFile != "" {
+		pem, err := os.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate in tls ca file: %s", config.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// HashTagKey 生成带哈希标签的键，集群模式下相同 tag 的键落在同一槽位，可用于需要放在一起的键
+// 例如 HashTagKey("user:1001", ":profile") 返回 "{user:1001}:profile"
+func HashTagKey(tag, key string) string {
+	return "{" + tag + "}" + key
+}
+
+// mget 批量读取完整键名对应的原始值，未命中的位置为 nil
+// 集群模式下键可能分布在不同槽位，MGET 会返回 CROSSSLOT 错误，改用按节点拆分的管道 GET
+func (r *RedisCache) mget(fullKeys []string) ([]interface{}, error) {
+	if !r.cluster || r.coLocated {
+		return r.client.MGet(r.ctx, fullKeys...).Result()
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(fullKeys))
+	for i, fullKey := range fullKeys {
+		cmds[i] = pipe.Get(r.ctx, fullKey)
+	}
+	if _, err := pipe.Exec(r.ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	vals := make([]interface{}, len(fullKeys))
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
 // getFullKey 获取完整键名
 func (r *RedisCache) getFullKey(key string) string {
 	return r.keyPrefix + key
 }
 
 // Get 获取缓存值
-func (r *RedisCache) Get(key string) (interface{}, bool, error) {
+func (r *RedisCache) Get(key string) (value interface{}, found bool, err error) {
+	defer r.stats.read(opGet, time.Now(), &found, &err)
 	fullKey := r.getFullKey(key)
 	val, err := r.client.Get(r.ctx, fullKey).Bytes()
 	if err != nil {
@@ -65,19 +226,20 @@ func (r *RedisCache) Get(key string) (interface{}, bool, error) {
 		return nil, false, fmt.Errorf("redis get failed: %w", err)
 	}
 
-	var result interface{}
-	if err := json.Unmarshal(val, &result); err != nil {
-		return nil, false, fmt.Errorf("json unmarshal failed: %w", err)
+	result, err := r.encoder.decode(val)
+	if err != nil {
+		return nil, false, err
 	}
 	return result, true, nil
 }
 
 // Set 设置缓存值
-func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration) error {
+func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	defer r.stats.done(opSet, time.Now(), &err)
 	fullKey := r.getFullKey(key)
-	val, err := json.Marshal(value)
+	val, err := r.encoder.encode(value)
 	if err != nil {
-		return fmt.Errorf("json marshal failed: %w", err)
+		return err
 	}
 
 	if expiration == -1 {
@@ -87,123 +249,174 @@ func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration
 }
 
 // Delete 删除缓存值
-func (r *RedisCache) Delete(key string) error {
+func (r *RedisCache) Delete(key string) (err error) {
+	defer r.stats.done(opDelete, time.Now(), &err)
 	fullKey := r.getFullKey(key)
 	return r.client.Del(r.ctx, fullKey).Err()
 }
 
 // SetHash 设置哈希表
-func (r *RedisCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) error {
+func (r *RedisCache) SetHash(key string, value map[string]interface{}, expiration time.Duration) (err error) {
+	defer r.stats.done(opSetHash, time.Now(), &err)
 	fullKey := r.getFullKey(key)
+	if err := r.hmset(fullKey, value); err != nil {
+		return err
+	}
 
+	// 设置过期时间
+	if expiration > 0 {
+		return r.client.Expire(r.ctx, fullKey, expiration).Err()
+	}
+	return nil
+}
+
+// UpdateHash 更新哈希表的部分字段，其余字段与过期时间保持不变，哈希表不存在时创建
+func (r *RedisCache) UpdateHash(key string, value map[string]interface{}) (err error) {
+	defer r.stats.done(opUpdateHash, time.Now(), &err)
+	return r.hmset(r.getFullKey(key), value)
+}
+
+// hmset 编码并写入哈希表字段
+func (r *RedisCache) hmset(fullKey string, value map[string]interface{}) error {
 	// 1. 类型标记转换：将 interface{} 转换为带类型前缀的字符串
-	markedValue := make(map[string]interface{}, len(value))
-	for field, val := range value {
-		switch v := val.(type) {
-		case bool:
-			if v {
-				markedValue[field] = "bool:true"
-			} else {
-				markedValue[field] = "bool:false"
-			}
-		case int, int32, int64, uint, uint32, uint64:
-			markedValue[field] = fmt.Sprintf("int:%v", v)
-		case float32, float64:
-			markedValue[field] = fmt.Sprintf("float:%v", v)
-		case string:
-			markedValue[field] = fmt.Sprintf("string:%v", v) // 字符串无需额外处理
-		case []byte:
-			markedValue[field] = fmt.Sprintf("bytes:%x", v) // 二进制转为十六进制
-		default:
-			// 其他复杂类型（如结构体）回退到 JSON 序列化
-			jsonData, err := json.Marshal(v)
+	marked, err := r.hashCodec.EncodeMap(value)
+	if err != nil {
+		return err
+	}
+	markedValue := make(map[string]interface{}, len(marked))
+	for field, str := range marked {
+		markedValue[field] = str
+	}
+
+	// 2. 配置了密钥时加密字段值
+	if r.encoder.keyring != nil {
+		for field, marked := range markedValue {
+			sealed, err := r.encoder.seal([]byte(marked.(string)))
 			if err != nil {
-				return fmt.Errorf("unsupported type for field %s: %w", field, err)
+				return fmt.Errorf("encrypt field %s failed: %w", field, err)
 			}
-			markedValue[field] = fmt.Sprintf("json:%s", jsonData)
+			markedValue[field] = sealed
 		}
 	}
 
-	// 2. 执行 Redis HMSet
+	// 3. 执行 Redis HMSet
 	if err := r.client.HMSet(r.ctx, fullKey, markedValue).Err(); err != nil {
 		return fmt.Errorf("redis hmset failed: %w", err)
 	}
-
-	// 3. 设置过期时间
-	if expiration > 0 {
-		return r.client.Expire(r.ctx, fullKey, expiration).Err()
-	}
 	return nil
 }
 
 // GetHash 获取整个哈希表
-func (r *RedisCache) GetHash(key string) (map[string]interface{}, error) {
+func (r *RedisCache) GetHash(key string) (hash map[string]interface{}, err error) {
+	defer func(start time.Time) { r.stats.lookup(opGetHash, start, len(hash) > 0, err) }(time.Now()) // 键不存在时返回空表
 	fullKey := r.getFullKey(key)
 	strMap, err := r.client.HGetAll(r.ctx, fullKey).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	result := make(map[string]interface{}, len(strMap))
 	for field, markedStr := range strMap {
-		// 按类型前缀解析值
-		parts := strings.SplitN(markedStr, ":", 2)
-		if len(parts) != 2 {
-			result[field] = markedStr // 无类型标记则保持原样
-			continue
-		}
-
-		switch parts[0] {
-		case "bool":
-			result[field] = parts[1] == "true"
-		case "int":
-			val, _ := strconv.ParseInt(parts[1], 10, 64)
-			result[field] = val
-		case "float":
-			val, _ := strconv.ParseFloat(parts[1], 64)
-			result[field] = val
-		case "string":
-			result[field] = parts[1]
-		case "bytes":
-			data, _ := hex.DecodeString(parts[1])
-			result[field] = data
-		case "json":
-			var data interface{}
-			if err := json.Unmarshal([]byte(parts[1]), &data); err == nil {
-				result[field] = data
-			} else {
-				result[field] = parts[1] // 解析失败保留原始 JSON 字符串
-			}
-		default:
-			result[field] = markedStr // 未知类型标记保持原样
+		if strMap[field], err = r.openHashValue(markedStr); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
 		}
 	}
-	return result, nil
+
+	// 按类型前缀解析值
+	return r.hashCodec.DecodeMap(strMap)
 }
 
 // GetHashField 获取哈希表字段
-func (r *RedisCache) GetHashField(key, field string) (string, error) {
+func (r *RedisCache) GetHashField(key, field string) (value string, err error) {
+	defer r.stats.done(opGetHashField, time.Now(), &err)
 	fullKey := r.getFullKey(key)
 	val, err := r.client.HGet(r.ctx, fullKey, field).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return "", fmt.Errorf("field %s not found in hash %s", field, key)
+			return "", missErrorf("field %s not found in hash %s", field, key)
 		}
 		return "", fmt.Errorf("redis hget failed: %w", err)
 	}
-	return val, nil
+	return r.openHashValue(val)
+}
+
+// GetHashFieldValue 获取哈希表字段并按类型标记解码，结果与 GetHash 中该字段一致
+func (r *RedisCache) GetHashFieldValue(key, field string) (interface{}, error) {
+	marked, err := r.GetHashField(key, field)
+	if err != nil {
+		return nil, err
+	}
+	return r.hashCodec.Decode(marked)
+}
+
+// GetHashInt 获取整数哈希字段，字段类型不符时返回 ErrTypeMismatch
+func (r *RedisCache) GetHashInt(key, field string) (int64, error) {
+	return hashInt(r.GetHashFieldValue(key, field))
+}
+
+// GetHashFloat 获取浮点数哈希字段，整数字段同样可以读取
+func (r *RedisCache) GetHashFloat(key, field string) (float64, error) {
+	return hashFloat(r.GetHashFieldValue(key, field))
+}
+
+// GetHashString 获取字符串哈希字段
+func (r *RedisCache) GetHashString(key, field string) (string, error) {
+	return hashString(r.GetHashFieldValue(key, field))
+}
+
+// GetHashBool 获取布尔哈希字段
+func (r *RedisCache) GetHashBool(key, field string) (bool, error) {
+	return hashBool(r.GetHashFieldValue(key, field))
+}
+
+// GetHashBytes 获取二进制哈希字段
+func (r *RedisCache) GetHashBytes(key, field string) ([]byte, error) {
+	return hashBytes(r.GetHashFieldValue(key, field))
+}
+
+// openHashValue 解密哈希表字段值，配置了密钥时拒绝未加密的值
+func (r *RedisCache) openHashValue(val string) (string, error) {
+	return r.encoder.openHashValue(val)
+}
+
+// SetHashStruct 将结构体的导出字段写入哈希表，字段名取自 `cache:"name,omitempty"` 标签
+func (r *RedisCache) SetHashStruct(key string, v interface{}, expiration time.Duration) error {
+	values, err := structToHash(v)
+	if err != nil {
+		return err
+	}
+	return r.SetHash(key, values, expiration)
+}
+
+// GetHashStruct 读取哈希表并写入 dst 指向的结构体，哈希表中不存在的字段保持原值
+func (r *RedisCache) GetHashStruct(key string, dst interface{}) error {
+	values, err := r.GetHash(key)
+	if err != nil {
+		return err
+	}
+	return hashToStruct(values, dst)
+}
+
+// UpdateHashStruct 只写入结构体中指定的字段（按哈希表字段名），未指定时写入全部字段，不影响其他字段与过期时间
+func (r *RedisCache) UpdateHashStruct(key string, v interface{}, fields ...string) error {
+	values, err := structToHash(v, fields...)
+	if err != nil {
+		return err
+	}
+	return r.UpdateHash(key, values)
 }
 
 // DelHash 删除哈希表字段
-func (r *RedisCache) DelHash(key, field string) error {
+func (r *RedisCache) DelHash(key, field string) (err error) {
+	defer r.stats.done(opDelHash, time.Now(), &err)
 	fullKey := r.getFullKey(key)
 	return r.client.HDel(r.ctx, fullKey, field).Err()
 }
 
 // ExistHash 检查哈希表字段是否存在
-func (r *RedisCache) ExistHash(key, field string) (bool, error) {
+func (r *RedisCache) ExistHash(key, field string) (exists bool, err error) {
+	defer r.stats.read(opExistHash, time.Now(), &exists, &err)
 	fullKey := r.getFullKey(key)
-	exists, err := r.client.HExists(r.ctx, fullKey, field).Result()
+	exists, err = r.client.HExists(r.ctx, fullKey, field).Result()
 	if err != nil {
 		return false, fmt.Errorf("redis hexists failed: %w", err)
 	}
@@ -211,20 +424,22 @@ func (r *RedisCache) ExistHash(key, field string) (bool, error) {
 }
 
 // ExpireHash 设置哈希表过期时间
-func (r *RedisCache) ExpireHash(key string, expiration time.Duration) error {
+func (r *RedisCache) ExpireHash(key string, expiration time.Duration) (err error) {
+	defer r.stats.done(opExpireHash, time.Now(), &err)
 	fullKey := r.getFullKey(key)
 	return r.client.Expire(r.ctx, fullKey, expiration).Err()
 }
 
 // MSet 批量设置缓存值
-func (r *RedisCache) MSet(values map[string]interface{}, expiration time.Duration) error {
+func (r *RedisCache) MSet(values map[string]interface{}, expiration time.Duration) (err error) {
+	defer func(start time.Time) { r.stats.bulk(opMSet, start, 0, 0, len(values), err) }(time.Now())
 	pipe := r.client.Pipeline()
 
 	for key, value := range values {
 		fullKey := r.getFullKey(key)
-		val, err := json.Marshal(value)
+		val, err := r.encoder.encode(value)
 		if err != nil {
-			return fmt.Errorf("json marshal failed for key %s: %w", key, err)
+			return fmt.Errorf("key %s: %w", key, err)
 		}
 
 		if expiration == -1 {
@@ -234,28 +449,29 @@ func (r *RedisCache) MSet(values map[string]interface{}, expiration time.Duratio
 		}
 	}
 
-	_, err := pipe.Exec(r.ctx)
+	_, err = pipe.Exec(r.ctx)
 	return err
 }
 
 // MGet 批量获取缓存值
-func (r *RedisCache) MGet(keys []string) (map[string]interface{}, error) {
+func (r *RedisCache) MGet(keys []string) (result map[string]interface{}, err error) {
+	defer func(start time.Time) { r.stats.bulk(opMGet, start, len(result), len(keys)-len(result), 0, err) }(time.Now())
 	fullKeys := make([]string, len(keys))
 	for i, key := range keys {
 		fullKeys[i] = r.getFullKey(key)
 	}
 
-	vals, err := r.client.MGet(r.ctx, fullKeys...).Result()
+	vals, err := r.mget(fullKeys)
 	if err != nil {
 		return nil, fmt.Errorf("redis mget failed: %w", err)
 	}
 
-	result := make(map[string]interface{}, len(keys))
+	result = make(map[string]interface{}, len(keys))
 	for i, key := range keys {
 		if vals[i] != nil {
-			var value interface{}
-			if err := json.Unmarshal([]byte(vals[i].(string)), &value); err != nil {
-				return nil, fmt.Errorf("json unmarshal failed for key %s: %w", key, err)
+			value, err := r.encoder.decode([]byte(vals[i].(string)))
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
 			}
 			result[key] = value
 		}
@@ -264,7 +480,223 @@ func (r *RedisCache) MGet(keys []string) (map[string]interface{}, error) {
 	return result, nil
 }
 
+// MSetBatch 批量设置缓存值，逐键返回写入结果
+// 单个键序列化失败或写入失败不会影响其他键，仅在管道整体无法执行时返回 error
+func (r *RedisCache) MSetBatch(values map[string]interface{}, expiration time.Duration) (result BatchResult, err error) {
+	defer r.stats.batch(opMSet, time.Now(), &result, &err)
+	if expiration == -1 {
+		expiration = 0
+	}
+
+	result = make(BatchResult, len(values))
+	cmds := make(map[string]*redis.StatusCmd, len(values))
+	pipe := r.client.Pipeline()
+
+	for key, value := range values {
+		val, err := r.encoder.encode(value)
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		cmds[key] = pipe.Set(r.ctx, r.getFullKey(key), val, expiration)
+	}
+
+	if len(cmds) == 0 {
+		return result, nil
+	}
+
+	// Exec 只返回第一个错误，连接错误也会写入每条命令，此处逐条检查
+	_, _ = pipe.Exec(r.ctx)
+
+	for key, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: fmt.Errorf("redis set failed: %w", err)}
+		} else {
+			result[key] = BatchItem{Status: BatchOK}
+		}
+	}
+
+	return result, nil
+}
+
+// MGetBatch 批量获取缓存值，逐键返回命中、未命中或解码错误
+// 单个键解码失败（如被其他服务写入了编解码器无法解析的数据）不会影响其他键
+func (r *RedisCache) MGetBatch(keys []string) (result BatchResult, err error) {
+	defer r.stats.batch(opMGet, time.Now(), &result, &err)
+	if len(keys) == 0 {
+		return BatchResult{}, nil
+	}
+
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = r.getFullKey(key)
+	}
+
+	vals, err := r.mget(fullKeys)
+	if err != nil {
+		return nil, fmt.Errorf("redis mget failed: %w", err)
+	}
+
+	result = make(BatchResult, len(keys))
+	for i, key := range keys {
+		if vals[i] == nil {
+			result[key] = BatchItem{Status: BatchMiss}
+			continue
+		}
+
+		str, ok := vals[i].(string)
+		if !ok {
+			result[key] = BatchItem{Status: BatchError, Err: fmt.Errorf("unexpected redis value type %T", vals[i])}
+			continue
+		}
+
+		value, err := r.encoder.decode([]byte(str))
+		if err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		result[key] = BatchItem{Status: BatchOK, Value: value}
+	}
+
+	return result, nil
+}
+
+// GetInto 获取缓存值并直接解码到 dst 指向的变量，dst 类型由调用方决定，避免先解码为 interface{} 再转换
+func (r *RedisCache) GetInto(key string, dst interface{}) (found bool, err error) {
+	defer r.stats.read(opGet, time.Now(), &found, &err)
+	val, err := r.client.Get(r.ctx, r.getFullKey(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("redis get failed: %w", err)
+	}
+	return true, r.encoder.decodeInto(val, dst)
+}
+
+// MGetInto 批量获取缓存值，newDst 为每个键创建目标指针，命中时 BatchItem.Value 为该指针
+func (r *RedisCache) MGetInto(keys []string, newDst func(key string) interface{}) (result BatchResult, err error) {
+	defer r.stats.batch(opMGet, time.Now(), &result, &err)
+	if len(keys) == 0 {
+		return BatchResult{}, nil
+	}
+
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = r.getFullKey(key)
+	}
+
+	vals, err := r.mget(fullKeys)
+	if err != nil {
+		return nil, fmt.Errorf("redis mget failed: %w", err)
+	}
+
+	result = make(BatchResult, len(keys))
+	for i, key := range keys {
+		if vals[i] == nil {
+			result[key] = BatchItem{Status: BatchMiss}
+			continue
+		}
+
+		str, ok := vals[i].(string)
+		if !ok {
+			result[key] = BatchItem{Status: BatchError, Err: fmt.Errorf("unexpected redis value type %T", vals[i])}
+			continue
+		}
+
+		dst := newDst(key)
+		if err := r.encoder.decodeInto([]byte(str), dst); err != nil {
+			result[key] = BatchItem{Status: BatchError, Err: err}
+			continue
+		}
+		result[key] = BatchItem{Status: BatchOK, Value: dst}
+	}
+
+	return result, nil
+}
+
+// Stats 返回统计信息，淘汰与过期次数来自键空间通知，仅在注册了事件监听器后统计
+func (r *RedisCache) Stats() Stats {
+	stats := r.stats.snapshot(CacheTypeRedis, &r.events)
+	stats.Prefix = r.keyPrefix
+	stats.Pool = r.client.PoolStats()
+	return stats
+}
+
+// OnEvict 注册删除及淘汰事件监听器（对应 Redis 的 del、evicted 键空间事件）
+// 需要 Redis 服务端开启键空间通知，例如: CONFIG SET notify-keyspace-events Egx
+func (r *RedisCache) OnEvict(listener EvictionListener) {
+	r.events.onEvict(listener)
+	r.startNotifications()
+}
+
+// OnExpire 注册过期事件监听器（对应 Redis 的 expired 键空间事件）
+// 需要 Redis 服务端开启键空间通知，例如: CONFIG SET notify-keyspace-events Egx
+func (r *RedisCache) OnExpire(listener EvictionListener) {
+	r.events.onExpire(listener)
+	r.startNotifications()
+}
+
+// startNotifications 首次注册监听器时订阅键空间事件
+// 键空间事件只在键所在节点发布，集群模式下需要分别订阅每个主节点
+func (r *RedisCache) startNotifications() {
+	r.notifyOnce.Do(func() {
+		channelPrefix := fmt.Sprintf("__keyevent@%d__:", r.db)
+		subscribe := func(client redis.UniversalClient) {
+			pubsub := client.Subscribe(r.ctx,
+				channelPrefix+"expired",
+				channelPrefix+"evicted",
+				channelPrefix+"del",
+			)
+			r.notifyMu.Lock()
+			r.pubsubs = append(r.pubsubs, pubsub)
+			r.notifyMu.Unlock()
+			go r.dispatchNotifications(pubsub.Channel(), channelPrefix)
+		}
+
+		if cc, ok := r.client.(*redis.ClusterClient); ok {
+			_ = cc.ForEachMaster(r.ctx, func(ctx context.Context, client *redis.Client) error {
+				subscribe(client)
+				return nil
+			})
+			return
+		}
+		subscribe(r.client)
+	})
+}
+
+// dispatchNotifications 将键空间事件转换为 EvictionEvent，仅处理带本实例前缀的键
+func (r *RedisCache) dispatchNotifications(ch <-chan *redis.Message, channelPrefix string) {
+	for msg := range ch {
+		if !strings.HasPrefix(msg.Payload, r.keyPrefix) {
+			continue
+		}
+
+		var reason EvictReason
+		switch strings.TrimPrefix(msg.Channel, channelPrefix) {
+		case "expired":
+			reason = EvictReasonExpired
+		case "evicted":
+			reason = EvictReasonCapacity
+		case "del":
+			reason = EvictReasonDeleted
+		default:
+			continue
+		}
+
+		r.events.emit(EvictionEvent{
+			Key:    strings.TrimPrefix(msg.Payload, r.keyPrefix),
+			Reason: reason,
+		})
+	}
+}
+
 // Close 关闭Redis连接
 func (r *RedisCache) Close() error {
+	r.notifyMu.Lock()
+	for _, pubsub := range r.pubsubs {
+		_ = pubsub.Close()
+	}
+	r.notifyMu.Unlock()
 	return r.client.Close()
 }
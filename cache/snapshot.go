@@ -0,0 +1,313 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 22:10:26
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 22:10:26
+ * Description: 内存缓存快照与恢复
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	snapshotMagic   = "goscache-snapshot"
+	snapshotVersion = 1
+)
+
+// snapshotHeader 快照文件头，Codec 为普通键值的编解码器名称
+type snapshotHeader struct {
+	Magic     string
+	Version   int
+	Codec     string
+	CreatedAt time.Time
+}
+
+// snapshotEntry 快照条目，普通键的 Value 为编解码器编码后的字节，哈希表字段为带类型标记的字符串
+// ExpiresAt 为绝对过期时间(UnixNano)，0 表示永不过期；End 为结束标记，用于发现被截断的快照
+type snapshotEntry struct {
+	Key       string
+	Value     []byte
+	Hash      map[string]string
+	IsHash    bool
+	ExpiresAt int64
+	Deleted   bool // 仅用于追加日志，表示键已删除
+	End       bool
+	Count     int
+}
+
+// Snapshot 将全部未过期的普通键与哈希表写入 w，过期时间以绝对时间保存
+// 各分片依次加锁导出，快照在分片内一致，不同分片之间不保证同一时刻
+func (m *MemoryCache) Snapshot(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	header := snapshotHeader{Magic: snapshotMagic, Version: snapshotVersion, Codec: m.codec.Name(), CreatedAt: time.Now()}
+	if err := enc.Encode(&header); err != nil {
+		return fmt.Errorf("write snapshot header failed: %w", err)
+	}
+
+	count := 0
+	for _, shard := range m.shards {
+		entries, err := shard.snapshot(m.codec)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			if err := enc.Encode(&entries[i]); err != nil {
+				return fmt.Errorf("write snapshot entry %s failed: %w", entries[i].Key, err)
+			}
+		}
+		count += len(entries)
+	}
+
+	if err := enc.Encode(&snapshotEntry{End: true, Count: count}); err != nil {
+		return fmt.Errorf("write snapshot trailer failed: %w", err)
+	}
+	return nil
+}
+
+// Restore 从 r 读取快照并写入缓存，已有的同名键被覆盖，快照中已过期的条目被跳过
+// 写入经过容量限制，超出上限时与普通写入一样按淘汰策略处理
+func (m *MemoryCache) Restore(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("read snapshot header failed: %w", err)
+	}
+	if header.Magic != snapshotMagic {
+		return fmt.Errorf("invalid snapshot format")
+	}
+	if header.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version: %d", header.Version)
+	}
+	if header.Codec != m.codec.Name() {
+		return fmt.Errorf("snapshot codec %s does not match cache codec %s", header.Codec, m.codec.Name())
+	}
+
+	now := time.Now()
+	count := 0
+	for {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("read snapshot entry failed: %w", err)
+		}
+		if entry.End {
+			if entry.Count != count {
+				return fmt.Errorf("snapshot entry count mismatch: expected %d, got %d", entry.Count, count)
+			}
+			return m.commit(nil)
+		}
+		count++
+
+		var expiresAt time.Time
+		if entry.ExpiresAt > 0 {
+			if expiresAt = time.Unix(0, entry.ExpiresAt); !expiresAt.After(now) {
+				continue
+			}
+		}
+		if err := m.shard(entry.Key).restore(&entry, expiresAt, m.codec); err != nil {
+			return err
+		}
+	}
+}
+
+// startSnapshots 启动时加载快照文件，并按间隔定期写入快照
+func (m *MemoryCache) startSnapshots(interval time.Duration) error {
+	if err := m.loadSnapshotFile(m.snapshotPath); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = m.saveSnapshotFile(m.snapshotPath) // 失败时保留上一次的快照，下个周期重试
+			case <-m.stopChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// loadSnapshotFile 加载快照文件，文件不存在时视为首次启动
+func (m *MemoryCache) loadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open snapshot %s failed: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := m.Restore(bufio.NewReader(f)); err != nil {
+		return fmt.Errorf("load snapshot %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// saveSnapshotFile 先写入同目录下的临时文件并同步到磁盘，再原子替换快照文件，写入中途崩溃不会损坏已有快照
+func (m *MemoryCache) saveSnapshotFile(path string) error {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create snapshot file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后为空操作
+
+	w := bufio.NewWriter(tmp)
+	err = m.Snapshot(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write snapshot %s failed: %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace snapshot %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// snapshot 导出分片内未过期的普通键与哈希表
+func (s *memoryShard) snapshot(codec Codec) ([]snapshotEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := s.cache.Items()
+	entries := make([]snapshotEntry, 0, len(items)+len(s.hashMaps))
+	for key, item := range items {
+		data, ok := item.Object.([]byte)
+		if s.codec == nil {
+			var err error
+			if data, err = marshalValue(codec, item.Object); err != nil {
+				return nil, fmt.Errorf("snapshot key %s: %w", key, err)
+			}
+		} else if !ok {
+			return nil, fmt.Errorf("snapshot key %s: unexpected stored value type %T", key, item.Object)
+		}
+		entries = append(entries, snapshotEntry{Key: key, Value: data, ExpiresAt: item.Expiration})
+	}
+
+	now := time.Now()
+	for key := range s.hashMaps {
+		if expiry, ok := s.hashExpirations[key]; ok && now.After(expiry) {
+			continue
+		}
+		entry, err := s.hashEntry(key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// hashEntry 将哈希表导出为条目，字段值为带类型标记的字符串，哈希表不存在时返回删除条目
+// 调用方需持有 s.mu
+func (s *memoryShard) hashEntry(key string) (snapshotEntry, error) {
+	hash, exists := s.hashMaps[key]
+	if !exists {
+		return snapshotEntry{Key: key, IsHash: true, Deleted: true}, nil
+	}
+
+	var expiresAt int64
+	if expiry, ok := s.hashExpirations[key]; ok {
+		expiresAt = expiry.UnixNano()
+	}
+	fields := make(map[string]string, len(hash))
+	for field, val := range hash {
+		marked, ok := val.(string)
+		if !ok {
+			var err error
+			if marked, err = s.hashCodec.Encode(val); err != nil {
+				return snapshotEntry{}, fmt.Errorf("snapshot hash %s field %s: %w", key, field, err)
+			}
+		}
+		fields[field] = marked
+	}
+	return snapshotEntry{Key: key, Hash: fields, IsHash: true, ExpiresAt: expiresAt}, nil
+}
+
+// restore 写入一条快照条目，expiresAt 为零值表示永不过期
+func (s *memoryShard) restore(entry *snapshotEntry, expiresAt time.Time, codec Codec) error {
+	ref := entryRef{key: entry.Key, hash: entry.IsHash}
+
+	var stored interface{}
+	if entry.IsHash {
+		hash := make(map[string]interface{}, len(entry.Hash))
+		for field, marked := range entry.Hash {
+			hash[field] = marked
+		}
+		stored = hash
+	} else if s.codec != nil {
+		stored = entry.Value
+	} else {
+		value, err := unmarshalValue(codec, entry.Value)
+		if err != nil {
+			return fmt.Errorf("restore key %s: %w", entry.Key, err)
+		}
+		stored = value
+	}
+
+	s.mu.Lock()
+	victims, admitted, err := s.admit(ref, estimateSize(entry.Key, stored))
+	if err == nil && admitted {
+		switch {
+		case !entry.IsHash:
+			expiration := cache.NoExpiration
+			if !expiresAt.IsZero() {
+				expiration = time.Until(expiresAt)
+			}
+			s.cache.Set(entry.Key, stored, expiration)
+		case expiresAt.IsZero():
+			s.hashMaps[entry.Key] = stored.(map[string]interface{})
+			delete(s.hashExpirations, entry.Key)
+		default:
+			s.hashMaps[entry.Key] = stored.(map[string]interface{})
+			s.hashExpirations[entry.Key] = expiresAt
+		}
+		s.appendLog(entry)
+	}
+	s.mu.Unlock()
+
+	if err == nil && !admitted {
+		s.rejected(ref, stored)
+	}
+	s.evict(victims)
+	return err
+}
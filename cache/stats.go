@@ -0,0 +1,274 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/19 16:40:12
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/19 16:40:12
+ * Description: 缓存统计：命中率、读写次数与操作耗时分布
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Stats 缓存统计信息，计数从创建缓存开始累计
+type Stats struct {
+	Backend     CacheType        // 缓存类型
+	Prefix      string           // 键前缀，仅 Redis 与多级缓存
+	Hits        uint64           // 读取命中次数，批量读取按键计数
+	Misses      uint64           // 读取未命中次数，键或哈希字段不存在、已过期
+	Sets        uint64           // 成功写入次数(Set、SetHash、UpdateHash)，批量写入按键计数
+	Deletes     uint64           // 成功删除次数(Delete、DelHash)
+	Evictions   uint64           // 容量不足被淘汰的条目数
+	Expirations uint64           // 过期移除的条目数
+	Errors      uint64           // 失败次数，批量操作按键计数，键或字段不存在不计入
+	Items       int              // 普通键数量，Redis 缓存为 0，多级缓存取自 L1
+	Hashes      int              // 哈希表数量，Redis 缓存为 0，多级缓存取自 L1
+	Pool        *redis.PoolStats // Redis 连接池统计，不使用 Redis 的缓存为 nil
+	Operations  map[string]OperationStats
+}
+
+// OperationStats 单个操作的调用次数与耗时分布
+type OperationStats struct {
+	Count    uint64          // 调用次数
+	Errors   uint64          // 失败次数
+	Duration time.Duration   // 累计耗时
+	Buckets  []LatencyBucket // 耗时直方图，按上界升序，计数为累积值
+}
+
+// LatencyBucket 耗时直方图的桶，Count 为耗时不超过 UpperBound 的调用次数
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// HitRatio 命中率，没有读取时返回 0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// operation 统计的操作，批量读写与类型化读取归入对应的基础操作
+type operation int
+
+const (
+	opGet          operation = iota // Get、GetInto
+	opSet                           // Set
+	opDelete                        // Delete
+	opMGet                          // MGet、MGetBatch、MGetInto
+	opMSet                          // MSet、MSetBatch
+	opGetHash                       // GetHash、GetHashStruct
+	opGetHashField                  // GetHashField、GetHashFieldValue 及类型化读取
+	opSetHash                       // SetHash、SetHashStruct
+	opUpdateHash                    // UpdateHash、UpdateHashStruct
+	opDelHash                       // DelHash
+	opExistHash                     // ExistHash
+	opExpireHash                    // ExpireHash
+	operationCount
+)
+
+var operationNames = [operationCount]string{
+	"Get", "Set", "Delete", "MGet", "MSet",
+	"GetHash", "GetHashField", "SetHash", "UpdateHash", "DelHash", "ExistHash", "ExpireHash",
+}
+
+// latencyBounds 耗时直方图各桶的上界
+var latencyBounds = [...]time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// opRecorder 单个操作的计数，buckets 为非累积计数，超过最大上界的调用只计入 count
+type opRecorder struct {
+	count    atomic.Uint64
+	errors   atomic.Uint64
+	duration atomic.Int64
+	buckets  [len(latencyBounds)]atomic.Uint64
+}
+
+// statsRecorder 统计计数器，全部使用原子操作，不引入额外的锁
+type statsRecorder struct {
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	sets    atomic.Uint64
+	deletes atomic.Uint64
+	errors  atomic.Uint64
+	ops     [operationCount]opRecorder
+}
+
+// missError 键或哈希字段不存在、已过期，统计时计为未命中而不是失败
+type missError struct {
+	msg string
+}
+
+func (e *missError) Error() string {
+	return e.msg
+}
+
+// missErrorf 按格式创建 missError
+func missErrorf(format string, args ...interface{}) error {
+	return &missError{msg: fmt.Sprintf(format, args...)}
+}
+
+// isMiss 是否为 missError
+func isMiss(err error) bool {
+	var miss *missError
+	return errors.As(err, &miss)
+}
+
+// observe 记录一次调用的耗时，failed 为失败的次数（批量操作按键计数）
+func (s *statsRecorder) observe(op operation, start time.Time, failed uint64) {
+	elapsed := time.Since(start)
+	r := &s.ops[op]
+	r.count.Add(1)
+	r.duration.Add(int64(elapsed))
+	for i, bound := range latencyBounds {
+		if elapsed <= bound {
+			r.buckets[i].Add(1)
+			break
+		}
+	}
+	if failed > 0 {
+		r.errors.Add(failed)
+		s.errors.Add(failed)
+	}
+}
+
+// done 记录一次单键操作，写操作成功时计入写入或删除次数，哈希表读取按是否返回 missError 计入命中或未命中
+// 以 defer 调用，err 指向命名返回值
+func (s *statsRecorder) done(op operation, start time.Time, err *error) {
+	var failed uint64
+	switch {
+	case *err == nil:
+		switch op {
+		case opSet, opSetHash, opUpdateHash:
+			s.sets.Add(1)
+		case opDelete, opDelHash:
+			s.deletes.Add(1)
+		case opGetHash, opGetHashField:
+			s.hits.Add(1)
+		}
+	case isMiss(*err):
+		if op == opGetHash || op == opGetHashField {
+			s.misses.Add(1)
+		}
+	default:
+		failed = 1
+	}
+	s.observe(op, start, failed)
+}
+
+// read 记录一次单键读取，以 defer 调用
+func (s *statsRecorder) read(op operation, start time.Time, found *bool, err *error) {
+	s.lookup(op, start, *found, *err)
+}
+
+// lookup 记录一次单键读取
+func (s *statsRecorder) lookup(op operation, start time.Time, found bool, err error) {
+	var failed uint64
+	switch {
+	case err != nil && !isMiss(err):
+		failed = 1
+	case found:
+		s.hits.Add(1)
+	default:
+		s.misses.Add(1)
+	}
+	s.observe(op, start, failed)
+}
+
+// batch 记录一次批量操作，按 BatchResult 逐键计入命中、未命中、写入与失败，以 defer 调用
+func (s *statsRecorder) batch(op operation, start time.Time, result *BatchResult, err *error) {
+	if *err != nil {
+		s.observe(op, start, 1)
+		return
+	}
+	var ok, miss, failed uint64
+	for _, item := range *result {
+		switch item.Status {
+		case BatchOK:
+			ok++
+		case BatchMiss:
+			miss++
+		default:
+			failed++
+		}
+	}
+	if op == opMSet {
+		s.sets.Add(ok)
+	} else {
+		s.hits.Add(ok)
+		s.misses.Add(miss)
+	}
+	s.observe(op, start, failed)
+}
+
+// bulk 记录一次返回值不是 BatchResult 的批量操作，成功时累加命中、未命中与写入次数
+func (s *statsRecorder) bulk(op operation, start time.Time, hits, misses, sets int, err error) {
+	if err != nil {
+		s.observe(op, start, 1)
+		return
+	}
+	s.hits.Add(uint64(hits))
+	s.misses.Add(uint64(misses))
+	s.sets.Add(uint64(sets))
+	s.observe(op, start, 0)
+}
+
+// snapshot 读取当前计数，各计数分别原子读取，相互之间不保证同一时刻
+func (s *statsRecorder) snapshot(backend CacheType, events *eventHub) Stats {
+	stats := Stats{
+		Backend:     backend,
+		Hits:        s.hits.Load(),
+		Misses:      s.misses.Load(),
+		Sets:        s.sets.Load(),
+		Deletes:     s.deletes.Load(),
+		Evictions:   events.evictions.Load(),
+		Expirations: events.expirations.Load(),
+		Errors:      s.errors.Load(),
+		Operations:  make(map[string]OperationStats, operationCount),
+	}
+	for op := range s.ops {
+		r := &s.ops[op]
+		// observe 先增加 count 再增加分桶，这里先读分桶再读 count，保证分桶计数不超过总数
+		buckets := make([]LatencyBucket, len(latencyBounds))
+		var cumulative uint64
+		for i, bound := range latencyBounds {
+			cumulative += r.buckets[i].Load()
+			buckets[i] = LatencyBucket{UpperBound: bound, Count: cumulative}
+		}
+		count := r.count.Load()
+		if count == 0 {
+			continue
+		}
+		stats.Operations[operationNames[op]] = OperationStats{
+			Count:    count,
+			Errors:   r.errors.Load(),
+			Duration: time.Duration(r.duration.Load()),
+			Buckets:  buckets,
+		}
+	}
+	return stats
+}
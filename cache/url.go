@@ -0,0 +1,293 @@
+/**
+ * @Author: guxline zjguoxin@163.com
+ * @Date: 2026/10/18 17:35:12
+ * @LastEditors: guxline zjguoxin@163.com
+ * @LastEditTime: 2026/10/18 17:35:12
+ * Description: 连接串配置解析
+ * Copyright: Copyright (©) 2025 中易综服. All rights reserved.
+ */
+package cache
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRedisPort = "6379"
+
+// NewCacheFromURL 根据连接串创建缓存实例，opts 在连接串之后应用，可覆盖连接串中的配置
+// 支持的格式:
+//   - redis://[user:password@]host[:port][/db][?prefix=app:&pool_size=100]
+//   - rediss://... 同 redis://，启用 TLS
+//   - memory://[?default_exp=5m&cleanup=10m]
+//   - file:///var/lib/app/cache.db[?sync=always&default_exp=5m]
+//   - arena://[?size=268435456&shards=64]
+func NewCacheFromURL(rawURL string, opts ...Option) (CacheInterface, error) {
+	config, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return newCache(config)
+}
+
+// ParseURL 将连接串解析为缓存配置，未指定的参数使用默认值
+//
+// redis/rediss 支持的参数: prefix、default_exp、hash_expiry、pool_size、min_idle_conns、
+// dial_timeout、read_timeout、write_timeout、tls_ca_file、tls_cert_file、tls_key_file
+//
+// memory 支持的参数: default_exp、cleanup、max_entries、max_bytes、eviction、admission、shards、
+// snapshot_path、snapshot_interval、aof_path、aof_sync、aof_rewrite_size
+//
+// file 支持的参数: default_exp、cleanup、sync
+//
+// arena 支持的参数: default_exp、cleanup、size、shards
+func ParseURL(rawURL string) (*CacheConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "redis", "rediss":
+		return parseRedisURL(u)
+	case "memory":
+		return parseMemoryURL(u)
+	case "file":
+		return parseFileURL(u)
+	case "arena":
+		return parseArenaURL(u)
+	case "":
+		return nil, fmt.Errorf("cache url missing scheme: %s", rawURL)
+	default:
+		return nil, fmt.Errorf("unsupported cache url scheme: %s", u.Scheme)
+	}
+}
+
+// parseRedisURL 解析 redis:// 与 rediss:// 连接串
+func parseRedisURL(u *url.URL) (*CacheConfig, error) {
+	config := DefaultConfig(CacheTypeRedis)
+	config.TLS = u.Scheme == "rediss"
+
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		return nil, fmt.Errorf("redis url missing host")
+	}
+	if port == "" {
+		port = defaultRedisPort
+	}
+	config.URL = net.JoinHostPort(host, port)
+
+	if u.User != nil {
+		config.Username = u.User.Username()
+		config.Password, _ = u.User.Password()
+	}
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid redis db in url: %s", db)
+		}
+		config.DB = n
+	}
+
+	err := applyURLParams(u.Query(), func(name, value string) (err error) {
+		switch name {
+		case "prefix":
+			config.Prefix = value
+		case "default_exp":
+			config.DefaultExp, err = parseDurationParam(name, value)
+		case "hash_expiry":
+			config.HashKeyExpiry, err = parseDurationParam(name, value)
+		case "pool_size":
+			config.PoolSize, err = parseIntParam(name, value)
+		case "min_idle_conns":
+			config.MinIdleConns, err = parseIntParam(name, value)
+		case "dial_timeout":
+			config.DialTimeout, err = parseDurationParam(name, value)
+		case "read_timeout":
+			config.ReadTimeout, err = parseDurationParam(name, value)
+		case "write_timeout":
+			config.WriteTimeout, err = parseDurationParam(name, value)
+		case "tls_ca_file":
+			config.TLSCAFile = value
+		case "tls_cert_file":
+			config.TLSCertFile = value
+		case "tls_key_file":
+			config.TLSKeyFile = value
+		default:
+			return fmt.Errorf("unknown redis url parameter: %s", name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !config.TLS && (config.TLSCAFile != "" || config.TLSCertFile != "" || config.TLSKeyFile != "") {
+		return nil, fmt.Errorf("tls parameters require rediss:// scheme")
+	}
+	return config, nil
+}
+
+// parseMemoryURL 解析 memory:// 连接串
+func parseMemoryURL(u *url.URL) (*CacheConfig, error) {
+	if u.Host != "" || strings.Trim(u.Path, "/") != "" || u.User != nil {
+		return nil, fmt.Errorf("memory url only accepts query parameters")
+	}
+
+	config := DefaultConfig(CacheTypeMemory)
+	err := applyURLParams(u.Query(), func(name, value string) (err error) {
+		switch name {
+		case "default_exp":
+			config.DefaultExp, err = parseDurationParam(name, value)
+		case "cleanup":
+			config.CleanupInt, err = parseDurationParam(name, value)
+		case "max_entries":
+			config.MaxEntries, err = parseIntParam(name, value)
+		case "max_bytes":
+			config.MaxBytes, err = parseInt64Param(name, value)
+		case "eviction":
+			config.EvictionPolicy = value
+		case "admission":
+			config.Admission = value
+		case "shards":
+			config.ShardCount, err = parseIntParam(name, value)
+		case "snapshot_path":
+			config.SnapshotPath = value
+		case "snapshot_interval":
+			config.SnapshotInterval, err = parseDurationParam(name, value)
+		case "aof_path":
+			config.AOFPath = value
+		case "aof_sync":
+			config.AOFSync = value
+		case "aof_rewrite_size":
+			config.AOFRewriteSize, err = parseInt64Param(name, value)
+		default:
+			return fmt.Errorf("unknown memory url parameter: %s", name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyURLParams 按参数名顺序逐个应用查询参数，同名参数以最后一个为准
+func applyURLParams(query url.Values, apply func(name, value string) error) error {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := query[name]
+		if err := apply(name, values[len(values)-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDurationParam 解析时长参数，如 5m、30s
+func parseDurationParam(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid url parameter %s=%q: %w", name, value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid url parameter %s=%q: must not be negative", name, value)
+	}
+	return d, nil
+}
+
+// parseIntParam 解析非负整数参数
+func parseIntParam(name, value string) (int, error) {
+	n, err := parseInt64Param(name, value)
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(n)) != n {
+		return 0, fmt.Errorf("invalid url parameter %s=%q: out of range", name, value)
+	}
+	return int(n), nil
+}
+
+// parseInt64Param 解析非负 64 位整数参数
+func parseInt64Param(name, value string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid url parameter %s=%q: %w", name, value, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid url parameter %s=%q: must not be negative", name, value)
+	}
+	return n, nil
+}
+
+// parseFileURL 解析 file:// 连接串，路径为数据文件路径
+func parseFileURL(u *url.URL) (*CacheConfig, error) {
+	if u.Host != "" || u.User != nil {
+		return nil, fmt.Errorf("file url must not contain host, use file:///path")
+	}
+	if u.Path == "" {
+		return nil, fmt.Errorf("file url missing path")
+	}
+
+	config := DefaultConfig(CacheTypeFile)
+	config.FilePath = u.Path
+	err := applyURLParams(u.Query(), func(name, value string) (err error) {
+		switch name {
+		case "default_exp":
+			config.DefaultExp, err = parseDurationParam(name, value)
+		case "cleanup":
+			config.CleanupInt, err = parseDurationParam(name, value)
+		case "sync":
+			config.FileSync = value
+		default:
+			return fmt.Errorf("unknown file url parameter: %s", name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseArenaURL 解析 arena:// 连接串
+func parseArenaURL(u *url.URL) (*CacheConfig, error) {
+	if u.Host != "" || strings.Trim(u.Path, "/") != "" || u.User != nil {
+		return nil, fmt.Errorf("arena url only accepts query parameters")
+	}
+
+	config := DefaultConfig(CacheTypeArena)
+	err := applyURLParams(u.Query(), func(name, value string) (err error) {
+		switch name {
+		case "default_exp":
+			config.DefaultExp, err = parseDurationParam(name, value)
+		case "cleanup":
+			config.CleanupInt, err = parseDurationParam(name, value)
+		case "size":
+			config.ArenaSize, err = parseInt64Param(name, value)
+		case "shards":
+			config.ShardCount, err = parseIntParam(name, value)
+		default:
+			return fmt.Errorf("unknown arena url parameter: %s", name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,607 @@
+package cache_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis 测试用的 Redis 替身，实现 RESP2 协议下缓存用到的命令子集，
+// 同时可以扮演 Sentinel，用于在没有 Redis 服务的环境下模拟故障转移
+type fakeRedis struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	strs    map[string]string
+	hashes  map[string]map[string]string
+	expires map[string]time.Time
+	subs    map[string]map[*fakeConn]bool
+	conns   map[*fakeConn]bool
+	closed  bool
+	auth    []string // 最近一次 AUTH 参数
+
+	// beforeReply 命令执行后、回复写出前调用，用于在读取结果返回前插入其他操作
+	beforeReply func(cmd string, args []string)
+
+	// 集群模式：CLUSTER SLOTS 报告自身负责全部槽位，键不在同一槽位的 MGET 返回 CROSSSLOT
+	cluster bool
+
+	// Sentinel 模式
+	masterName string
+	masterAddr string
+}
+
+// fakeConn 客户端连接，订阅消息与命令回复可能并发写入
+type fakeConn struct {
+	net.Conn
+	wmu sync.Mutex
+}
+
+func (c *fakeConn) write(data string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, _ = io.WriteString(c.Conn, data)
+}
+
+// startFakeRedis 启动监听本地随机端口的替身服务，测试结束时自动关闭
+func startFakeRedis(t testing.TB) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动替身Redis失败: %v", err)
+	}
+	return newFakeRedis(t, ln)
+}
+
+// startFakeRedisTLS 启动使用 TLS 的替身服务
+func startFakeRedisTLS(t testing.TB, config *tls.Config) *fakeRedis {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("启动替身Redis失败: %v", err)
+	}
+	return newFakeRedis(t, ln)
+}
+
+func newFakeRedis(t testing.TB, ln net.Listener) *fakeRedis {
+	f := &fakeRedis{
+		ln:      ln,
+		strs:    make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		expires: make(map[string]time.Time),
+		subs:    make(map[string]map[*fakeConn]bool),
+		conns:   make(map[*fakeConn]bool),
+	}
+	go f.serve()
+	t.Cleanup(f.Close)
+	return f
+}
+
+// startFakeSentinel 启动替身 Sentinel，报告 master 为指定地址
+func startFakeSentinel(t testing.TB, masterName, masterAddr string) *fakeRedis {
+	f := startFakeRedis(t)
+	f.masterName = masterName
+	f.masterAddr = masterAddr
+	return f
+}
+
+// startFakeCluster 启动扮演单节点集群的替身服务
+func startFakeCluster(t testing.TB) *fakeRedis {
+	f := startFakeRedis(t)
+	f.cluster = true
+	return f
+}
+
+func (f *fakeRedis) Addr() string {
+	return f.ln.Addr().String()
+}
+
+// Close 停止服务并断开所有连接，模拟节点宕机
+func (f *fakeRedis) Close() {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	conns := f.conns
+	f.conns = make(map[*fakeConn]bool)
+	f.mu.Unlock()
+
+	_ = f.ln.Close()
+	for c := range conns {
+		_ = c.Close()
+	}
+}
+
+// Failover Sentinel 切换 master 并向订阅者发布 +switch-master
+func (f *fakeRedis) Failover(newAddr string) {
+	f.mu.Lock()
+	oldHost, oldPort, _ := net.SplitHostPort(f.masterAddr)
+	newHost, newPort, _ := net.SplitHostPort(newAddr)
+	f.masterAddr = newAddr
+	f.mu.Unlock()
+
+	f.publish("+switch-master", strings.Join([]string{f.masterName, oldHost, oldPort, newHost, newPort}, " "))
+}
+
+// setBeforeReply 设置回复写出前的回调，nil 表示清除
+func (f *fakeRedis) setBeforeReply(hook func(cmd string, args []string)) {
+	f.mu.Lock()
+	f.beforeReply = hook
+	f.mu.Unlock()
+}
+
+// lastAuth 返回最近一次 AUTH 命令的参数
+func (f *fakeRedis) lastAuth() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.auth
+}
+
+// value 读取字符串键的原始值，用于断言数据写入了哪个节点
+func (f *fakeRedis) value(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.strs[key]
+	return v, ok
+}
+
+// hashValue 读取哈希表字段的原始值
+func (f *fakeRedis) hashValue(key, field string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.hashes[key][field]
+	return v, ok
+}
+
+// setValue 直接写入字符串键的原始值，模拟其他服务写入的数据
+func (f *fakeRedis) setValue(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.strs[key] = value
+}
+
+// setHashField 直接写入哈希表字段的原始值，模拟其他服务写入的数据
+func (f *fakeRedis) setHashField(key, field, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hashes[key] == nil {
+		f.hashes[key] = make(map[string]string)
+	}
+	f.hashes[key][field] = value
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &fakeConn{Conn: conn}
+		f.mu.Lock()
+		if f.closed {
+			f.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		f.conns[c] = true
+		f.mu.Unlock()
+		go f.handle(c)
+	}
+}
+
+func (f *fakeRedis) handle(c *fakeConn) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, c)
+		for _, subs := range f.subs {
+			delete(subs, c)
+		}
+		f.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		cmd := strings.ToLower(args[0])
+		reply := f.exec(c, cmd, args[1:])
+		f.mu.Lock()
+		hook := f.beforeReply
+		f.mu.Unlock()
+		if hook != nil {
+			hook(cmd, args[1:])
+		}
+		c.write(reply)
+	}
+}
+
+// readCommand 读取一条 RESP 数组格式的命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil // 内联命令
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimRight(header, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func respOK() string            { return "+OK\r\n" }
+func respErr(msg string) string { return "-" + msg + "\r\n" }
+func respInt(n int) string      { return ":" + strconv.Itoa(n) + "\r\n" }
+func respNull() string          { return "$-1\r\n" }
+func respBulk(s string) string  { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+func respArray(items []string) string {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		b.WriteString(item)
+	}
+	return b.String()
+}
+
+// expireLocked 惰性删除过期键并发布 expired 键空间事件，调用方需持有 f.mu
+func (f *fakeRedis) expireLocked(key string) []string {
+	if exp, ok := f.expires[key]; ok && time.Now().After(exp) {
+		delete(f.strs, key)
+		delete(f.hashes, key)
+		delete(f.expires, key)
+		return []string{key}
+	}
+	return nil
+}
+
+func (f *fakeRedis) existsLocked(key string) bool {
+	_, isStr := f.strs[key]
+	_, isHash := f.hashes[key]
+	return isStr || isHash
+}
+
+func (f *fakeRedis) exec(c *fakeConn, cmd string, args []string) string {
+	switch cmd {
+	case "hello", "client":
+		return respErr("ERR unknown command '" + cmd + "'")
+	case "ping":
+		return "+PONG\r\n"
+	case "auth":
+		f.mu.Lock()
+		f.auth = args
+		f.mu.Unlock()
+		return respOK()
+	case "select":
+		return respOK()
+	case "subscribe":
+		f.mu.Lock()
+		var reply strings.Builder
+		for i, ch := range args {
+			if f.subs[ch] == nil {
+				f.subs[ch] = make(map[*fakeConn]bool)
+			}
+			f.subs[ch][c] = true
+			reply.WriteString(respArray([]string{respBulk("subscribe"), respBulk(ch), respInt(i + 1)}))
+		}
+		f.mu.Unlock()
+		return reply.String()
+	case "unsubscribe":
+		f.mu.Lock()
+		for _, subs := range f.subs {
+			delete(subs, c)
+		}
+		f.mu.Unlock()
+		return respArray([]string{respBulk("unsubscribe"), respNull(), respInt(0)})
+	case "publish":
+		if len(args) != 2 {
+			return respErr("ERR wrong number of arguments")
+		}
+		return respInt(f.publish(args[0], args[1]))
+	case "sentinel":
+		return f.sentinel(args)
+	case "cluster":
+		return f.clusterSlots(args)
+	}
+	if f.cluster && cmd == "mget" && !sameSlot(args) {
+		return respErr("CROSSSLOT Keys in request don't hash to the same slot")
+	}
+
+	f.mu.Lock()
+	var expired, deleted []string
+	for _, key := range args {
+		expired = append(expired, f.expireLocked(key)...)
+	}
+	reply := f.execData(cmd, args, &deleted)
+	f.mu.Unlock()
+
+	for _, key := range expired {
+		f.publish("__keyevent@0__:expired", key)
+	}
+	for _, key := range deleted {
+		f.publish("__keyevent@0__:del", key)
+	}
+	return reply
+}
+
+// execData 执行数据命令，调用方需持有 f.mu
+func (f *fakeRedis) execData(cmd string, args []string, deleted *[]string) string {
+	switch cmd {
+	case "get":
+		if v, ok := f.strs[args[0]]; ok {
+			return respBulk(v)
+		}
+		return respNull()
+	case "set":
+		key := args[0]
+		delete(f.hashes, key)
+		delete(f.expires, key)
+		f.strs[key] = args[1]
+		for i := 2; i+1 < len(args); i += 2 {
+			n, _ := strconv.Atoi(args[i+1])
+			switch strings.ToLower(args[i]) {
+			case "ex":
+				f.expires[key] = time.Now().Add(time.Duration(n) * time.Second)
+			case "px":
+				f.expires[key] = time.Now().Add(time.Duration(n) * time.Millisecond)
+			}
+		}
+		return respOK()
+	case "mget":
+		items := make([]string, len(args))
+		for i, key := range args {
+			if v, ok := f.strs[key]; ok {
+				items[i] = respBulk(v)
+			} else {
+				items[i] = respNull()
+			}
+		}
+		return respArray(items)
+	case "del":
+		n := 0
+		for _, key := range args {
+			if f.existsLocked(key) {
+				delete(f.strs, key)
+				delete(f.hashes, key)
+				delete(f.expires, key)
+				*deleted = append(*deleted, key)
+				n++
+			}
+		}
+		return respInt(n)
+	case "exists":
+		n := 0
+		for _, key := range args {
+			if f.existsLocked(key) {
+				n++
+			}
+		}
+		return respInt(n)
+	case "expire", "pexpire":
+		key := args[0]
+		if !f.existsLocked(key) {
+			return respInt(0)
+		}
+		n, _ := strconv.Atoi(args[1])
+		unit := time.Second
+		if cmd == "pexpire" {
+			unit = time.Millisecond
+		}
+		if n <= 0 {
+			delete(f.strs, key)
+			delete(f.hashes, key)
+			delete(f.expires, key)
+			*deleted = append(*deleted, key)
+		} else {
+			f.expires[key] = time.Now().Add(time.Duration(n) * unit)
+		}
+		return respInt(1)
+	case "pttl":
+		if !f.existsLocked(args[0]) {
+			return respInt(-2)
+		}
+		exp, ok := f.expires[args[0]]
+		if !ok {
+			return respInt(-1)
+		}
+		return respInt(int(time.Until(exp) / time.Millisecond))
+	case "hset", "hmset":
+		key := args[0]
+		delete(f.strs, key)
+		if f.hashes[key] == nil {
+			f.hashes[key] = make(map[string]string)
+		}
+		n := 0
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, ok := f.hashes[key][args[i]]; !ok {
+				n++
+			}
+			f.hashes[key][args[i]] = args[i+1]
+		}
+		if cmd == "hmset" {
+			return respOK()
+		}
+		return respInt(n)
+	case "hgetall":
+		var items []string
+		for field, v := range f.hashes[args[0]] {
+			items = append(items, respBulk(field), respBulk(v))
+		}
+		return respArray(items)
+	case "hget":
+		if v, ok := f.hashes[args[0]][args[1]]; ok {
+			return respBulk(v)
+		}
+		return respNull()
+	case "hdel":
+		n := 0
+		for _, field := range args[1:] {
+			if _, ok := f.hashes[args[0]][field]; ok {
+				delete(f.hashes[args[0]], field)
+				n++
+			}
+		}
+		if h, ok := f.hashes[args[0]]; ok && len(h) == 0 {
+			delete(f.hashes, args[0])
+			delete(f.expires, args[0])
+			*deleted = append(*deleted, args[0])
+		}
+		return respInt(n)
+	case "hexists":
+		if _, ok := f.hashes[args[0]][args[1]]; ok {
+			return respInt(1)
+		}
+		return respInt(0)
+	default:
+		return respErr(fmt.Sprintf("ERR unknown command '%s'", cmd))
+	}
+}
+
+// sentinel 处理 SENTINEL 子命令
+func (f *fakeRedis) sentinel(args []string) string {
+	if len(args) < 2 || f.masterName == "" {
+		return respErr("ERR unknown command 'sentinel'")
+	}
+	switch strings.ToLower(args[0]) {
+	case "get-master-addr-by-name":
+		f.mu.Lock()
+		addr := f.masterAddr
+		f.mu.Unlock()
+		if args[1] != f.masterName {
+			return "*-1\r\n"
+		}
+		host, port, _ := net.SplitHostPort(addr)
+		return respArray([]string{respBulk(host), respBulk(port)})
+	case "sentinels", "replicas", "slaves":
+		return respArray(nil)
+	default:
+		return respErr("ERR unknown sentinel subcommand")
+	}
+}
+
+// clusterSlots 处理 CLUSTER SLOTS，报告自身负责全部槽位
+func (f *fakeRedis) clusterSlots(args []string) string {
+	if !f.cluster || len(args) == 0 || strings.ToLower(args[0]) != "slots" {
+		return respErr("ERR This instance has cluster support disabled")
+	}
+	host, portStr, _ := net.SplitHostPort(f.Addr())
+	port, _ := strconv.Atoi(portStr)
+	node := respArray([]string{respBulk(host), respInt(port), respBulk("fake-node")})
+	return respArray([]string{respArray([]string{respInt(0), respInt(16383), node})})
+}
+
+// sameSlot 判断键是否落在同一集群槽位
+func sameSlot(keys []string) bool {
+	for _, key := range keys[1:] {
+		if keySlot(key) != keySlot(keys[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// keySlot 计算键的集群槽位，键中包含非空哈希标签时只取标签内容
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % 16384
+}
+
+// publish 向频道订阅者推送消息，返回接收者数量
+func (f *fakeRedis) publish(channel, payload string) int {
+	f.mu.Lock()
+	subs := make([]*fakeConn, 0, len(f.subs[channel]))
+	for c := range f.subs[channel] {
+		subs = append(subs, c)
+	}
+	f.mu.Unlock()
+
+	msg := respArray([]string{respBulk("message"), respBulk(channel), respBulk(payload)})
+	for _, c := range subs {
+		c.write(msg)
+	}
+	return len(subs)
+}
+
+// newTestCertificate 生成 127.0.0.1 的自签名证书，同时作为 CA 使用
+func newTestCertificate(t testing.TB) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成私钥失败: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goscache test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("编码私钥失败: %v", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}